@@ -0,0 +1,99 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const attrPathTestString = `<Item>
+  <LargeImage>
+    <URL>http://example.com/large.jpg</URL>
+    <Height Units="pixels">500</Height>
+    <Width Units="pixels">375</Width>
+  </LargeImage>
+  <Offers>
+    <Offer id="a"/><Offer id="b"/><Offer/>
+  </Offers>
+</Item>`
+
+func (s *lXMLSuite) TestUnmarshalAttrPath(c *C) {
+	var v struct {
+		URL         string   `xml:"LargeImage>URL"`
+		Height      int      `xml:"LargeImage>Height"`
+		HeightUnits string   `xml:"LargeImage>Height>@Units,required"`
+		WidthUnits  string   `xml:"LargeImage>Width>Units,attr"`
+		OfferIds    []string `xml:"Offers>Offer>@id"`
+		Default     string   `xml:"LargeImage>URL>@type,default=jpeg"`
+	}
+	c.Assert(Unmarshal([]byte(attrPathTestString), &v), IsNil)
+	c.Check(v.URL, Equals, "http://example.com/large.jpg")
+	c.Check(v.Height, Equals, 500)
+	c.Check(v.HeightUnits, Equals, "pixels")
+	c.Check(v.WidthUnits, Equals, "pixels")
+	c.Check(v.OfferIds, DeepEquals, []string{"a", "b"})
+	c.Check(v.Default, Equals, "jpeg")
+
+	var a struct {
+		Units []string `xml:"LargeImage>*>@Units"`
+	}
+	c.Assert(Unmarshal([]byte(attrPathTestString), &a), IsNil)
+	c.Check(a.Units, DeepEquals, []string{"pixels", "pixels"})
+
+	// Attributes below the element of a field.
+	var b struct {
+		Large struct {
+			URL string
+		} `xml:"LargeImage"`
+		Units  string   `xml:"LargeImage>Height>@Units"`
+		Either []string `xml:"LargeImage>>Width>@Units"`
+	}
+	c.Assert(Unmarshal([]byte(attrPathTestString), &b), IsNil)
+	c.Check(b.Large.URL, Equals, "http://example.com/large.jpg")
+	c.Check(b.Units, Equals, "pixels")
+	c.Check(b.Either, DeepEquals, []string{"pixels"})
+
+	var r struct {
+		Missing string `xml:"LargeImage>URL>@type,required"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &r), ErrorMatches,
+		`xml: line 1: <Item> lacks required attribute "LargeImage>URL>type"`)
+
+	var f struct {
+		Units string `xml:"LargeImage>Height>@Units,enum=cm|mm"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &f), ErrorMatches,
+		`xml: /Item/LargeImage/Height/@Units: value "pixels" violates enum=cm\|mm`)
+}
+
+func (s *lXMLSuite) TestUnmarshalAttrPathInvalid(c *C) {
+	var v1 struct {
+		A string `xml:"LargeImage>@Units,chardata"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &v1), ErrorMatches, `xml: invalid tag in field A .*`)
+
+	var v2 struct {
+		A string `xml:"LargeImage>>@Units"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &v2), ErrorMatches, `xml: invalid path in field A .*`)
+
+	var v3 struct {
+		A string `xml:"LargeImage>Height>@Units"`
+		B string `xml:"LargeImage>Height>Units,attr"`
+		C string `xml:"LargeImage,attr"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &v3), ErrorMatches,
+		`struct .* field "A" with tag "LargeImage>Height>@Units" conflicts with field "B" .*`)
+
+	var v4 struct {
+		A string `xml:"LargeImage>>Height>@Units"`
+		B string `xml:"LargeImage>Image>Height>@Units"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &v4), ErrorMatches,
+		`struct .* field "A" with tag "LargeImage>>Height>@Units" conflicts with field "B" .*`)
+
+	var v5 struct {
+		A string `xml:"LargeImage>>Height>@Units"`
+		B string `xml:"LargeImage>Width>@Units"`
+		C string `xml:"LargeImage>Height>@Size"`
+	}
+	c.Check(Unmarshal([]byte(attrPathTestString), &v5), IsNil)
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// isBigType tells whether typ is one of the math/big number types.
+func isBigType(typ reflect.Type) bool {
+	return typ == bigIntType || typ == bigFloatType || typ == bigRatType
+}
+
+// copyBig stores the number in src in dst, which must be an addressable
+// big.Int, big.Float or big.Rat. big.Int takes xs:integer values,
+// big.Rat represents xs:decimal values exactly. A big.Float without a
+// precision gets one that keeps all digits of src.
+func copyBig(dst reflect.Value, src string) error {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	// The value is parsed into a new number, so dst is left alone if
+	// src does not parse.
+	var v interface{}
+	ok := false
+	switch dst.Type() {
+	case bigIntType:
+		v, ok = new(big.Int).SetString(src, 10)
+	case bigFloatType:
+		f := dst.Addr().Interface().(*big.Float)
+		prec := f.Prec()
+		if prec == 0 {
+			prec = floatPrec(src)
+		}
+		v, ok = new(big.Float).SetPrec(prec).SetMode(f.Mode()).SetString(src)
+	case bigRatType:
+		v, ok = new(big.Rat).SetString(src)
+	}
+	if !ok {
+		return fmt.Errorf("xml: cannot parse %q as %s", src, dst.Type())
+	}
+	dst.Set(reflect.ValueOf(v).Elem())
+	return nil
+}
+
+// floatPrec returns the precision in bits needed for the decimal
+// digits of the mantissa of the number in src, at least the 64 bits
+// big.Float uses by default.
+func floatPrec(src string) uint {
+	digits := 0
+	for _, r := range src {
+		if r == 'e' || r == 'E' {
+			break
+		}
+		if '0' <= r && r <= '9' {
+			digits++
+		}
+	}
+	prec := uint(math.Ceil(float64(digits) * math.Log2(10)))
+	if prec < 64 {
+		prec = 64
+	}
+	return prec
+}
@@ -0,0 +1,92 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"math/big"
+)
+
+const bigTestString = `<Offer total="123456789012345678901234567890">
+  <Price> 19.99 </Price>
+  <Rate>1e-3</Rate>
+  <Amounts><Amount>0.10</Amount><Amount>-3/4</Amount></Amounts>
+</Offer>`
+
+func (s *lXMLSuite) TestUnmarshalBig(c *C) {
+	var v struct {
+		Total   big.Int `xml:"total,attr"`
+		Price   *big.Rat
+		Rate    big.Float
+		Amounts []*big.Rat `xml:"Amounts>Amount"`
+		Missing *big.Int
+	}
+	c.Assert(Unmarshal([]byte(bigTestString), &v), IsNil)
+
+	total, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	c.Check(v.Total.Cmp(total), Equals, 0)
+	c.Check(v.Price.Cmp(big.NewRat(1999, 100)), Equals, 0)
+	rate, _ := v.Rate.Float64()
+	c.Check(rate, Equals, 0.001)
+	c.Assert(v.Amounts, HasLen, 2)
+	c.Check(v.Amounts[0].Cmp(big.NewRat(1, 10)), Equals, 0)
+	c.Check(v.Amounts[1].Cmp(big.NewRat(-3, 4)), Equals, 0)
+	c.Check(v.Missing, IsNil)
+
+	const pi = "3.1415926535897932384626433832795028841971693993751"
+	var p struct {
+		Pi  big.Float
+		Low big.Float
+	}
+	p.Low.SetPrec(24)
+	c.Assert(Unmarshal([]byte(`<P><Pi>`+pi+`</Pi><Low>`+pi+`</Low></P>`), &p), IsNil)
+	c.Check(p.Pi.Text('f', 49), Equals, pi)
+	c.Check(p.Low.Prec(), Equals, uint(24))
+
+	var bad struct {
+		Price big.Int
+	}
+	c.Check(Unmarshal([]byte(bigTestString), &bad), ErrorMatches, `xml: cannot parse "19.99" as big.Int`)
+}
+
+func (s *lXMLSuite) TestUnmarshalNumberSizes(c *C) {
+	var v struct {
+		I8  int8
+		U16 uint16
+		F32 float32
+		I   int
+	}
+	c.Assert(Unmarshal([]byte(`<N><I8> -128 </I8><U16>65535</U16><F32>1.5</F32><I></I></N>`), &v), IsNil)
+	c.Check(v.I8, Equals, int8(-128))
+	c.Check(v.U16, Equals, uint16(65535))
+	c.Check(v.F32, Equals, float32(1.5))
+	c.Check(v.I, Equals, 0)
+
+	for _, data := range []string{
+		`<N><I8>128</I8></N>`,
+		`<N><U16>65536</U16></N>`,
+		`<N><U16>-1</U16></N>`,
+		`<N><F32>1e39</F32></N>`,
+	} {
+		c.Check(Unmarshal([]byte(data), &v), ErrorMatches, `strconv\.Parse.*`, Commentf(data))
+	}
+}
+
+func (s *lXMLSuite) TestUnmarshalNumbersInvalid(c *C) {
+	var v struct {
+		Price big.Int `xml:"price,attr"`
+		Small int8    `xml:"small,attr"`
+		Total big.Rat `xml:",chardata"`
+	}
+	v.Price.SetInt64(7)
+	c.Check(Unmarshal([]byte(`<P price="12x"/>`), &v), ErrorMatches, `xml: cannot parse "12x" as big.Int`)
+	c.Check(v.Price.Int64(), Equals, int64(7))
+	c.Check(Unmarshal([]byte(`<P small="300"/>`), &v), ErrorMatches, `strconv.ParseInt: parsing "300": value out of range`)
+	c.Check(Unmarshal([]byte(`<P>1/0</P>`), &v), ErrorMatches, `xml: cannot parse "1/0" as big.Rat`)
+
+	var w struct {
+		Small int8       `xml:",chardata"`
+		Rate  *big.Float `xml:"rate,attr"`
+	}
+	c.Check(Unmarshal([]byte(`<P>-129</P>`), &w), ErrorMatches, `strconv.ParseInt: parsing "-129": value out of range`)
+	c.Check(Unmarshal([]byte(`<P rate="1.5e"/>`), &w), ErrorMatches, `xml: cannot parse "1.5e" as big.Float`)
+	c.Check(w.Rate, IsNil)
+}
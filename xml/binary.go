@@ -0,0 +1,52 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// isBinaryType tells whether the base64 and hex options may be used on
+// a field of type typ: a []byte, or a slice of them for repeated
+// elements, possibly behind pointers or in an Optional.
+func isBinaryType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	typ = optionalElem(typ)
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+// decodeBinary returns the bytes of src as the base64 or hex option of
+// finfo specify, or the raw text of src without them. xs:base64Binary
+// and xs:hexBinary values may be wrapped over several lines.
+func decodeBinary(src string, finfo *fieldInfo) ([]byte, error) {
+	if finfo == nil || finfo.flags&fBinary == 0 {
+		return []byte(src), nil
+	}
+
+	src = strings.Map(dropSpace, src)
+	if finfo.flags&fHex != 0 {
+		return hex.DecodeString(src)
+	}
+	if len(src)%4 != 0 {
+		return base64.RawStdEncoding.DecodeString(src)
+	}
+	return base64.StdEncoding.DecodeString(src)
+}
+
+func dropSpace(r rune) rune {
+	if unicode.IsSpace(r) {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const binaryTestString = `<Document digest="DEADbeef">
+  <Image>
+    R29s
+    eG1s
+  </Image>
+  <Raw>R29seG1s</Raw>
+  <Unpadded>R29seG1sIQ</Unpadded>
+  <Signature>00 01
+    fe ff</Signature>
+  <Part>AQ==</Part><Part>Ag==</Part>
+</Document>`
+
+func (s *lXMLSuite) TestUnmarshalBinary(c *C) {
+	var v struct {
+		Digest    []byte `xml:"digest,attr,hex"`
+		Image     []byte `xml:",base64"`
+		Raw       []byte
+		Unpadded  *[]byte  `xml:",base64"`
+		Signature []byte   `xml:",hex"`
+		Parts     [][]byte `xml:"Part,base64"`
+	}
+	c.Assert(Unmarshal([]byte(binaryTestString), &v), IsNil)
+	c.Check(v.Digest, DeepEquals, []byte{0xde, 0xad, 0xbe, 0xef})
+	c.Check(string(v.Image), Equals, "Golxml")
+	c.Check(string(v.Raw), Equals, "R29seG1s")
+	c.Check(string(*v.Unpadded), Equals, "Golxml!")
+	c.Check(v.Signature, DeepEquals, []byte{0, 1, 0xfe, 0xff})
+	c.Check(v.Parts, DeepEquals, [][]byte{{1}, {2}})
+}
+
+func (s *lXMLSuite) TestUnmarshalBinaryErrors(c *C) {
+	var v1 struct {
+		Raw []byte `xml:",hex"`
+	}
+	c.Check(Unmarshal([]byte(binaryTestString), &v1), ErrorMatches, "encoding/hex: invalid byte.*")
+
+	var v2 struct {
+		Raw string `xml:",base64"`
+	}
+	c.Check(Unmarshal([]byte(binaryTestString), &v2), ErrorMatches, "xml: invalid tag in field Raw .*")
+
+	var v3 struct {
+		Raw []byte `xml:",base64,hex"`
+	}
+	c.Check(Unmarshal([]byte(binaryTestString), &v3), ErrorMatches, "xml: invalid tag in field Raw .*")
+}
@@ -0,0 +1,79 @@
+package xml
+
+import (
+	"context"
+	. "launchpad.net/gocheck"
+)
+
+// contextTestItem cancels the decode it belongs to with
+// contextTestCancel once the item with id 100 is decoded.
+type contextTestItem struct {
+	Id int `xml:"id,attr"`
+}
+
+var contextTestCancel context.CancelFunc
+
+func (i *contextTestItem) AfterUnmarshalXML() error {
+	if i.Id == 100 {
+		contextTestCancel()
+	}
+	return nil
+}
+
+type contextTestResult struct {
+	Items []*contextTestItem `xml:"Items>Item"`
+}
+
+func (s *lXMLSuite) TestDecodeContextCanceled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var v ECSResponse
+	c.Check(UnmarshalContext(ctx, s.ecs_xml, &v), Equals, context.Canceled)
+	c.Check(UnmarshalHTMLContext(ctx, []byte(htmlTestString), &v), Equals, context.Canceled)
+}
+
+func (s *lXMLSuite) TestDecodeContextDuringParsing(c *C) {
+	data := parallelTestData(5000, -1)
+	c.Assert(len(data) > 2*parseChunkSize, Equals, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := 0
+	testHookParseChunk = func() {
+		chunks++
+		cancel()
+	}
+	defer func() { testHookParseChunk = nil }()
+
+	var v parallelTestResult
+	c.Check(UnmarshalContext(ctx, data, &v), Equals, context.Canceled)
+	c.Check(chunks, Equals, 1)
+	c.Check(v.Items, IsNil)
+}
+
+func (s *lXMLSuite) TestDecodeContextDuringBinding(c *C) {
+	data := parallelTestData(1000, -1)
+
+	var ctx context.Context
+	ctx, contextTestCancel = context.WithCancel(context.Background())
+	var v contextTestResult
+	c.Check(UnmarshalContext(ctx, data, &v), Equals, context.Canceled)
+	c.Check(len(v.Items) > 100, Equals, true)
+	c.Check(len(v.Items) < 1000, Equals, true)
+
+	ctx, contextTestCancel = context.WithCancel(context.Background())
+	var pv contextTestResult
+	d := &Decoder{Parallel: 4, ParallelMin: 10}
+	c.Check(d.DecodeContext(ctx, data, &pv), Equals, context.Canceled)
+}
+
+func (s *lXMLSuite) TestDecodeContext(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var v, want parallelTestResult
+	data := parallelTestData(1000, -1)
+	c.Assert(Unmarshal(data, &want), IsNil)
+	c.Assert(UnmarshalContext(ctx, data, &v), IsNil)
+	c.Check(v, DeepEquals, want)
+}
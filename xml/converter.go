@@ -0,0 +1,90 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	stringType = reflect.TypeOf("")
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterConverter registers fn, a func(string) (T, error), to decode
+// the text of elements and attributes into values of type T. It takes
+// precedence over the built-in conversions, so types without methods
+// of their own can be decoded. It is an error if fn is no converter.
+func (d *Decoder) RegisterConverter(fn interface{}) error {
+	f, err := converterFunc(fn)
+	if err != nil {
+		return err
+	}
+	if d.converters == nil {
+		d.converters = make(map[reflect.Type]reflect.Value)
+	}
+	d.converters[f.Type().Out(0)] = f
+	return nil
+}
+
+// RegisterNamedConverter registers fn, a func(string) (T, error), as
+// the converter called name. Fields of type T tagged conv=name are
+// decoded with it instead of the converter for T. It is an error if
+// fn is no converter.
+func (d *Decoder) RegisterNamedConverter(name string, fn interface{}) error {
+	f, err := converterFunc(fn)
+	if err != nil {
+		return err
+	}
+	if d.namedConverters == nil {
+		d.namedConverters = make(map[string]reflect.Value)
+	}
+	d.namedConverters[name] = f
+	return nil
+}
+
+// converterFunc returns the value of fn, or an error if it is not a
+// converter.
+func converterFunc(fn interface{}) (reflect.Value, error) {
+	f := reflect.ValueOf(fn)
+	if !f.IsValid() {
+		return reflect.Value{}, errors.New("xml: converter must be a func(string) (T, error), not nil")
+	}
+	t := f.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.In(0) != stringType ||
+		t.NumOut() != 2 || t.Out(1) != errorType {
+		return reflect.Value{}, fmt.Errorf("xml: converter must be a func(string) (T, error), not %s", t)
+	}
+	return f, nil
+}
+
+// converter returns the converter for values of type typ of the field
+// finfo, if any. An unknown converter name is an error.
+func (p *decodeState) converter(typ reflect.Type, finfo *fieldInfo) (reflect.Value, error) {
+	if finfo != nil && finfo.conv != "" {
+		fn, ok := p.namedConverters[finfo.conv]
+		if !ok {
+			return reflect.Value{}, UnmarshalError("xml: unknown converter " + strconv.Quote(finfo.conv))
+		}
+		if fn.Type().Out(0) == typ {
+			return fn, nil
+		}
+		return reflect.Value{}, nil
+	}
+	return p.converters[typ], nil
+}
+
+// convert stores src converted by fn in dst.
+func convert(fn reflect.Value, dst reflect.Value, src string) error {
+	out := fn.Call([]reflect.Value{reflect.ValueOf(src)})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return err
+	}
+	dst.Set(out[0])
+	return nil
+}
@@ -0,0 +1,120 @@
+package xml
+
+import (
+	"errors"
+	. "launchpad.net/gocheck"
+	"strconv"
+	"strings"
+)
+
+// converterTestMoney stands in for a third-party type.
+type converterTestMoney struct {
+	Cents int64
+}
+
+func converterTestParseMoney(s string) (converterTestMoney, error) {
+	f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if err != nil {
+		return converterTestMoney{}, err
+	}
+	return converterTestMoney{int64(f*100 + 0.5)}, nil
+}
+
+func converterTestYesNo(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return false, errors.New("not yes or no: " + s)
+}
+
+const converterTestString = `<Product instock="Yes">
+  <Price>12,50</Price>
+  <Shipping>3,99</Shipping>
+  <Gift>n</Gift>
+  <Gift>y</Gift>
+  <Count>1.234</Count>
+  <Active>true</Active>
+</Product>`
+
+func converterTestDecoder() *Decoder {
+	d := new(Decoder)
+	d.RegisterConverter(converterTestParseMoney)
+	d.RegisterNamedConverter("yesno", converterTestYesNo)
+	d.RegisterNamedConverter("thousands", func(s string) (int, error) {
+		return strconv.Atoi(strings.Replace(s, ".", "", -1))
+	})
+	return d
+}
+
+func (s *lXMLSuite) TestDecodeConverter(c *C) {
+	var v struct {
+		InStock  bool `xml:"instock,attr,conv=yesno"`
+		Price    converterTestMoney
+		Shipping *converterTestMoney
+		Gift     []bool `xml:",conv=yesno"`
+		Count    int    `xml:",conv=thousands"`
+		Active   bool
+	}
+	c.Assert(converterTestDecoder().Decode([]byte(converterTestString), &v), IsNil)
+	c.Check(v.InStock, Equals, true)
+	c.Check(v.Price, Equals, converterTestMoney{1250})
+	c.Check(*v.Shipping, Equals, converterTestMoney{399})
+	c.Check(v.Gift, DeepEquals, []bool{false, true})
+	c.Check(v.Count, Equals, 1234)
+	c.Check(v.Active, Equals, true)
+}
+
+func (s *lXMLSuite) TestDecodeConverterErrors(c *C) {
+	var v1 struct {
+		Active bool `xml:",conv=yesno"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(converterTestString), &v1), ErrorMatches, "not yes or no: true")
+
+	var v2 struct {
+		Active bool `xml:",conv=onoff"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(converterTestString), &v2), ErrorMatches, `xml: unknown converter "onoff"`)
+
+	var v3 struct {
+		Active string `xml:",conv=yesno"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(converterTestString), &v3), ErrorMatches, `xml: converter "yesno" does not return string`)
+
+	const attrs = `<Product instock="maybe" price="1,x" />`
+	var v4 struct {
+		InStock bool `xml:"instock,attr,conv=yesno"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(attrs), &v4), ErrorMatches, "not yes or no: maybe")
+
+	var v5 struct {
+		InStock bool `xml:"instock,attr,conv=onoff"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(attrs), &v5), ErrorMatches, `xml: unknown converter "onoff"`)
+
+	var v6 struct {
+		Price []converterTestMoney `xml:"price,attr"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(attrs), &v6), ErrorMatches, `strconv.ParseFloat: parsing "1.x": invalid syntax`)
+	c.Check(v6.Price, HasLen, 0)
+
+	var v7 struct {
+		Price *converterTestMoney `xml:"price,attr"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(attrs), &v7), ErrorMatches, `strconv.ParseFloat: parsing "1.x": invalid syntax`)
+	c.Check(v7.Price, IsNil)
+	c.Assert(converterTestDecoder().Decode([]byte(`<Product price="1,5"/>`), &v7), IsNil)
+	c.Check(*v7.Price, Equals, converterTestMoney{150})
+
+	var v8 struct {
+		Active bool `xml:",chardata,conv=yesno"`
+	}
+	c.Check(converterTestDecoder().Decode([]byte(`<P>maybe</P>`), &v8), ErrorMatches, "not yes or no: maybe")
+
+	c.Check(new(Decoder).RegisterConverter(strconv.Itoa), ErrorMatches,
+		`xml: converter must be a func\(string\) \(T, error\), not func\(int\) string`)
+	c.Check(new(Decoder).RegisterNamedConverter("none", nil), ErrorMatches,
+		`xml: converter must be a func\(string\) \(T, error\), not nil`)
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	"fmt"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+)
+
+// A RequiredError is returned when an element lacks the element or
+// attribute of a field tagged required.
+type RequiredError struct {
+	// Element is the name of the element and Line its line in the
+	// document.
+	Element string
+	Line    int
+
+	// Name is the path of the missing element, or the name of the
+	// missing attribute.
+	Name string
+	Attr bool
+}
+
+func (e *RequiredError) Error() string {
+	kind := "element"
+	if e.Attr {
+		kind = "attribute"
+	}
+	return fmt.Sprintf("xml: line %d: <%s> lacks required %s %q", e.Line, e.Element, kind, e.Name)
+}
+
+// checkDefault checks that the default value of finfo can be stored in
+// a field of type typ. Only values whose decoding does not depend on
+// the Decoder are checked here, others are checked when the default is
+// applied: time.Time values without a layout option use the layouts
+// and Location of the Decoder, and other types its converters.
+func checkDefault(typ reflect.Type, finfo *fieldInfo) error {
+	if finfo.conv != "" {
+		return nil
+	}
+	t := typ
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return errors.New("slice of elements")
+	}
+	t = optionalElem(t)
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Slice,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		if !isBigType(t) && (t != timeType || finfo.layouts == nil) {
+			return nil
+		}
+	}
+	p := &decodeState{Decoder: new(Decoder)}
+	return p.setDefault(reflect.New(typ).Elem(), finfo)
+}
+
+// setDefault stores the default value of finfo in fv. An Optional
+// field gets the default as its Value but is not marked present.
+func (p *decodeState) setDefault(fv reflect.Value, finfo *fieldInfo) error {
+	for {
+		switch {
+		case fv.Kind() == reflect.Ptr:
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		case isOptional(fv.Type()):
+			fv = fv.Field(0)
+		default:
+			return p.copyValue(fv, finfo.def, finfo)
+		}
+	}
+}
+
+// seenFields records the fields with a default value or required
+// that were bound while decoding an element.
+type seenFields []*fieldInfo
+
+// add records finfo if it has a default value or is required. It does
+// nothing on a nil s.
+func (s *seenFields) add(finfo *fieldInfo) {
+	if s != nil && finfo.flags&(fDefault|fRequired) != 0 && !s.has(finfo) {
+		*s = append(*s, finfo)
+	}
+}
+
+// has tells whether finfo was recorded.
+func (s seenFields) has(finfo *fieldInfo) bool {
+	for _, f := range s {
+		if f == finfo {
+			return true
+		}
+	}
+	return false
+}
+
+// checkField applies the default value of finfo to its field in sv, or
+// reports it as missing if it is required, unless seen has the field.
+func (p *decodeState) checkField(sv reflect.Value, start gokoxml.Node, finfo *fieldInfo, seen seenFields) error {
+	switch {
+	case seen.has(finfo):
+		return nil
+	case finfo.flags&fRequired != 0:
+		name := finfo.name
+		for i := len(finfo.parents) - 1; i >= 0; i-- {
+			name = finfo.parents[i] + ">" + name
+		}
+		return &RequiredError{start.Name(), start.LineNumber(), name, finfo.flags&fAttr != 0}
+	}
+	if err := p.setDefault(sv.FieldByIndex(finfo.idx), finfo); err != nil {
+		return fmt.Errorf("xml: invalid default in field %s of type %s: %v",
+			sv.Type().FieldByIndex(finfo.idx).Name, sv.Type(), err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package xml
+
+import (
+	"fmt"
+	. "launchpad.net/gocheck"
+	"time"
+)
+
+const defaultsTestString = `<Order id="1">
+  <Item sku="a" currency="USD">
+    <Price>10</Price>
+  </Item>
+  <Item sku="b">
+    <Price>20</Price>
+    <Note>fragile</Note>
+  </Item>
+</Order>`
+
+type defaultsTestItem struct {
+	Sku      string  `xml:"sku,attr,required"`
+	Currency string  `xml:"currency,attr,default=EUR"`
+	Price    float64 `xml:"Price,required"`
+	Note     *string `xml:",default=none"`
+	Quantity int     `xml:",default=1"`
+}
+
+func (s *lXMLSuite) TestUnmarshalDefaults(c *C) {
+	var v struct {
+		Id      int                `xml:"id,attr,required"`
+		Channel string             `xml:"channel,attr,default=web"`
+		Placed  time.Time          `xml:",default=2012-01-02,layout=xs:date"`
+		Items   []defaultsTestItem `xml:"Item"`
+	}
+	c.Assert(Unmarshal([]byte(defaultsTestString), &v), IsNil)
+	c.Check(v.Id, Equals, 1)
+	c.Check(v.Channel, Equals, "web")
+	c.Check(v.Placed.Format("2006-01-02"), Equals, "2012-01-02")
+	c.Assert(v.Items, HasLen, 2)
+	c.Check(v.Items[0].Currency, Equals, "USD")
+	c.Check(v.Items[1].Currency, Equals, "EUR")
+	c.Check(*v.Items[0].Note, Equals, "none")
+	c.Check(*v.Items[1].Note, Equals, "fragile")
+	c.Check(v.Items[1].Quantity, Equals, 1)
+	c.Check(v.Items[1].Price, Equals, float64(20))
+}
+
+func (s *lXMLSuite) TestUnmarshalDefaultsOptional(c *C) {
+	var v struct {
+		Cur   Optional[string]  `xml:"Cur,default=EUR"`
+		Rate  *Optional[int]    `xml:"rate,attr,default=3"`
+		Price Optional[float64] `xml:"Price,default=1"`
+	}
+	c.Assert(Unmarshal([]byte(`<P><Price>2.5</Price></P>`), &v), IsNil)
+	c.Check(v.Cur, Equals, Optional[string]{Value: "EUR"})
+	c.Check(*v.Rate, Equals, Optional[int]{Value: 3})
+	c.Check(v.Price, Equals, Optional[float64]{Value: 2.5, Present: true})
+}
+
+func (s *lXMLSuite) TestUnmarshalRequired(c *C) {
+	var v1 struct {
+		Id    int `xml:"id,attr"`
+		Items []struct {
+			Sku    string `xml:"sku,attr"`
+			Amount string `xml:"Price>Amount,required"`
+		} `xml:"Item"`
+	}
+	err := Unmarshal([]byte(defaultsTestString), &v1)
+	c.Check(err, ErrorMatches, `xml: line 2: <Item> lacks required element "Price>Amount"`)
+	c.Check(err, DeepEquals, &RequiredError{"Item", 2, "Price>Amount", false})
+
+	var v2 struct {
+		Customer string `xml:"customer,attr,required"`
+	}
+	c.Check(Unmarshal([]byte(defaultsTestString), &v2), ErrorMatches,
+		`xml: line 1: <Order> lacks required attribute "customer"`)
+}
+
+func (s *lXMLSuite) TestUnmarshalRequiredSlice(c *C) {
+	var v struct {
+		Items []defaultsTestItem `xml:"Item,required"`
+	}
+	for _, parallel := range []int{0, 2} {
+		d := &Decoder{Parallel: parallel, ParallelMin: 1}
+		v.Items = nil
+		c.Assert(d.Decode([]byte(defaultsTestString), &v), IsNil)
+		c.Check(v.Items, HasLen, 2)
+		c.Check(d.Decode([]byte(`<Order/>`), &v), ErrorMatches,
+			`xml: line 1: <Order> lacks required element "Item"`)
+	}
+}
+
+func (s *lXMLSuite) TestUnmarshalDefaultsInvalid(c *C) {
+	var v1 struct {
+		Count int `xml:",default=many"`
+	}
+	c.Check(Unmarshal([]byte(defaultsTestString), &v1), ErrorMatches,
+		`xml: invalid default in field Count of type struct.*: strconv.ParseInt: parsing "many": invalid syntax`)
+
+	var v2 struct {
+		Items []string `xml:"Item,default=x"`
+	}
+	c.Check(Unmarshal([]byte(defaultsTestString), &v2), ErrorMatches,
+		`xml: invalid default in field Items of type struct.*: slice of elements`)
+
+	var v3 struct {
+		Text string `xml:",chardata,required"`
+	}
+	c.Check(Unmarshal([]byte(defaultsTestString), &v3), ErrorMatches, `xml: invalid tag in field Text .*`)
+}
+
+type defaultsTestPoint struct {
+	X, Y int
+}
+
+func (s *lXMLSuite) TestUnmarshalDefaultsDecoder(c *C) {
+	var v struct {
+		Shipped time.Time         `xml:",default=03.01.2012-10:30"`
+		Origin  defaultsTestPoint `xml:",default=1;2"`
+	}
+	loc := time.FixedZone("CET", 3600)
+	d := &Decoder{TimeLayouts: []string{"02.01.2006-15:04"}, Location: loc}
+	c.Assert(d.RegisterConverter(func(s string) (defaultsTestPoint, error) {
+		var p defaultsTestPoint
+		_, err := fmt.Sscanf(s, "%d;%d", &p.X, &p.Y)
+		return p, err
+	}), IsNil)
+	c.Assert(d.Decode([]byte(defaultsTestString), &v), IsNil)
+	c.Check(v.Shipped.Equal(time.Date(2012, 1, 3, 10, 30, 0, 0, loc)), Equals, true)
+	c.Check(v.Origin, Equals, defaultsTestPoint{1, 2})
+
+	c.Check(Unmarshal([]byte(defaultsTestString), &v), ErrorMatches,
+		`xml: invalid default in field Shipped of type struct.*: parsing time "03.01.2012-10:30".*`)
+
+	var v2 struct {
+		Placed time.Time `xml:",default=03.01.2012,layout=xs:date"`
+	}
+	c.Check(Unmarshal([]byte(defaultsTestString), &v2), ErrorMatches,
+		`xml: invalid default in field Placed of type struct.*: parsing time "03.01.2012".*`)
+}
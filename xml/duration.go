@@ -0,0 +1,140 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationUnits lists the designators of ISO 8601 durations in the
+// order they must appear, with "T" separating date and time parts.
+// Years and months have no fixed length and cannot be represented
+// unless they are zero.
+var durationUnits = []struct {
+	designator string
+	scale      time.Duration
+}{
+	{"Y", 0},
+	{"M", 0},
+	{"W", 7 * 24 * time.Hour},
+	{"D", 24 * time.Hour},
+	{"TH", time.Hour},
+	{"TM", time.Minute},
+	{"TS", time.Second},
+}
+
+// parseDuration parses an xs:duration or ISO 8601 duration such as
+// "PT1H30M" or "-P1DT2H", or a Go duration string such as "1h30m".
+func parseDuration(src string) (time.Duration, error) {
+	s := strings.TrimSpace(src)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return time.ParseDuration(strings.TrimSpace(src))
+	}
+	s = s[1:]
+
+	invalid := func() (time.Duration, error) {
+		return 0, fmt.Errorf("xml: invalid duration %q", src)
+	}
+	if s == "" || strings.HasSuffix(s, "T") {
+		return invalid()
+	}
+
+	var (
+		d      time.Duration
+		inTime bool
+		next   int // index of the next allowed unit
+	)
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return invalid()
+			}
+			inTime, s = true, s[1:]
+			continue
+		}
+
+		i := strings.IndexAny(s, "YMWDHS")
+		if i <= 0 || !isDecimal(s[:i]) {
+			return invalid()
+		}
+		number, designator := s[:i], s[i:i+1]
+		if inTime {
+			designator = "T" + designator
+		}
+		s = s[i+1:]
+
+		unit := next
+		for unit < len(durationUnits) && durationUnits[unit].designator != designator {
+			unit++
+		}
+		if unit == len(durationUnits) {
+			return invalid()
+		}
+		next = unit + 1
+
+		scale := durationUnits[unit].scale
+		if scale == 0 {
+			if strings.Trim(number, "0.,") == "" {
+				continue
+			}
+			return 0, fmt.Errorf("xml: duration %q has a calendar component (%s) that cannot be represented", src, designator)
+		}
+		v, ok := scaleDuration(number, scale)
+		if !ok || d > math.MaxInt64-v {
+			return 0, fmt.Errorf("xml: duration %q out of range", src)
+		}
+		d += v
+	}
+
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// isDecimal tells whether number is an unsigned decimal number. ISO
+// 8601 allows ',' as decimal separator.
+func isDecimal(number string) bool {
+	whole, frac := number, ""
+	if i := strings.IndexAny(number, ".,"); i >= 0 {
+		whole, frac = number[:i], number[i+1:]
+	}
+	return whole != "" && strings.IndexFunc(whole+frac, isNotDigit) < 0
+}
+
+// scaleDuration returns the decimal number times scale, or false if
+// the result overflows.
+func scaleDuration(number string, scale time.Duration) (time.Duration, bool) {
+	whole, frac := number, ""
+	if i := strings.IndexAny(number, ".,"); i >= 0 {
+		whole, frac = number[:i], number[i+1:]
+	}
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > math.MaxInt64/int64(scale) {
+		return 0, false
+	}
+	d := time.Duration(w) * scale
+	if frac != "" {
+		f, _ := strconv.ParseFloat("0."+frac, 64)
+		d += time.Duration(f * float64(scale))
+	}
+	return d, d >= 0
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
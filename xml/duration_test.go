@@ -0,0 +1,69 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"time"
+)
+
+var durationTests = []struct {
+	in  string
+	out time.Duration
+	err string
+}{
+	{"PT1H30M", 90 * time.Minute, ""},
+	{"P1DT2H", 26 * time.Hour, ""},
+	{"-P1D", -24 * time.Hour, ""},
+	{"P2W", 14 * 24 * time.Hour, ""},
+	{" PT1.5S\n", 1500 * time.Millisecond, ""},
+	{"PT0,25H", 15 * time.Minute, ""},
+	{"PT36H", 36 * time.Hour, ""},
+	{"P0D", 0, ""},
+	{"1h30m", 90 * time.Minute, ""},
+	{"-1.5s", -1500 * time.Millisecond, ""},
+	{"P0Y0M1DT2H", 26 * time.Hour, ""},
+	{"P0,0YT0.0M", 0, ""},
+	{"P1Y", 0, `.*calendar component \(Y\).*`},
+	{"P0Y1M", 0, `.*calendar component \(M\).*`},
+	{"P1M", 0, `.*calendar component \(M\).*`},
+	{"P", 0, `xml: invalid duration "P"`},
+	{"PT", 0, `xml: invalid duration "PT"`},
+	{"P1H", 0, `xml: invalid duration "P1H"`},
+	{"PT1M1H", 0, `xml: invalid duration "PT1M1H"`},
+	{"PTxS", 0, `xml: invalid duration "PTxS"`},
+	{"P99999999999D", 0, `xml: duration "P99999999999D" out of range`},
+	{"3600", 0, `time: missing unit in duration .*`},
+}
+
+func (s *lXMLSuite) TestParseDuration(c *C) {
+	for _, t := range durationTests {
+		d, err := parseDuration(t.in)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf("%q", t.in))
+			continue
+		}
+		c.Check(err, IsNil, Commentf("%q", t.in))
+		c.Check(d, Equals, t.out, Commentf("%q", t.in))
+	}
+}
+
+func (s *lXMLSuite) TestUnmarshalDuration(c *C) {
+	var v struct {
+		Timeout  time.Duration `xml:"timeout,attr"`
+		Interval time.Duration
+		Steps    []time.Duration `xml:"Step"`
+		Optional *time.Duration
+	}
+	data := `<Job timeout="PT30S">
+	  <Interval>P1DT12H</Interval>
+	  <Step>PT1M</Step><Step>90s</Step>
+	  <Optional>PT0S</Optional>
+	</Job>`
+	c.Assert(Unmarshal([]byte(data), &v), IsNil)
+	c.Check(v.Timeout, Equals, 30*time.Second)
+	c.Check(v.Interval, Equals, 36*time.Hour)
+	c.Check(v.Steps, DeepEquals, []time.Duration{time.Minute, 90 * time.Second})
+	c.Check(*v.Optional, Equals, time.Duration(0))
+
+	c.Check(Unmarshal([]byte(`<Job><Interval>P1M</Interval></Job>`), &v), ErrorMatches, ".*calendar component.*")
+	c.Check(Unmarshal([]byte(`<Job timeout="P1Y"/>`), &v), ErrorMatches, `xml: duration "P1Y" has a calendar component \(Y\).*`)
+}
@@ -0,0 +1,171 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	"fmt"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A FacetError is returned when a value violates one of the facets
+// given in the tag of its field.
+type FacetError struct {
+	// Path is the XPath of the element or attribute of the value.
+	Path string
+
+	// Facet is the violated option as written in the tag.
+	Facet string
+	Value string
+}
+
+func (e *FacetError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("xml: value %q violates %s", e.Value, e.Facet)
+	}
+	return fmt.Sprintf("xml: %s: value %q violates %s", e.Path, e.Value, e.Facet)
+}
+
+// A facet constrains the values of a field, like the facets of XML
+// Schema types do. enum and pattern apply to the text of the value,
+// min and max to numbers, minLength and maxLength to the characters of
+// strings and the bytes of []byte values. Values with commas are
+// quoted, as in pattern='[A-Z]{1,3}', values with spaces are given in
+// the xmlopt tag.
+type facet struct {
+	name, value string
+
+	enum    []string
+	pattern *regexp.Regexp
+	bound   float64
+	length  int
+}
+
+// newFacet returns the facet given by the tag option name=value.
+func newFacet(name, value string) (*facet, error) {
+	f := &facet{name: name, value: value}
+	var err error
+	switch name {
+	case "enum":
+		f.enum = strings.Split(value, "|")
+	case "pattern":
+		// Like in XML Schema, patterns match the whole value.
+		f.pattern, err = regexp.Compile("^(?:" + value + ")$")
+	case "min", "max":
+		f.bound, err = strconv.ParseFloat(value, 64)
+	case "minLength", "maxLength":
+		f.length, err = strconv.Atoi(value)
+		if err == nil && f.length < 0 {
+			err = errors.New("negative length")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkFacetType checks that the facets of finfo apply to the values of
+// a field of type typ.
+func checkFacetType(typ reflect.Type, finfo *fieldInfo) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	typ = optionalElem(typ)
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 {
+		typ = typ.Elem()
+	}
+	for _, f := range finfo.facets {
+		switch f.name {
+		case "min", "max":
+			if !isNumber(typ.Kind()) {
+				return errors.New(f.name + " on non-numeric type " + typ.String())
+			}
+		case "minLength", "maxLength":
+			if typ.Kind() != reflect.String && typ.Kind() != reflect.Slice {
+				return errors.New(f.name + " on type " + typ.String())
+			}
+		}
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// checkFacets checks v, decoded from src, against the facets of finfo.
+func checkFacets(v reflect.Value, src string, finfo *fieldInfo) error {
+	text := strings.TrimSpace(src)
+	if v.Kind() == reflect.String {
+		text = v.String()
+	}
+	for _, f := range finfo.facets {
+		if !f.valid(v, text) {
+			return &FacetError{Facet: f.name + "=" + f.value, Value: text}
+		}
+	}
+	return nil
+}
+
+// valid tells whether v with the text text satisfies f.
+func (f *facet) valid(v reflect.Value, text string) bool {
+	switch f.name {
+	case "enum":
+		for _, e := range f.enum {
+			if e == text {
+				return true
+			}
+		}
+		return false
+	case "pattern":
+		return f.pattern.MatchString(text)
+	case "min":
+		return number(v) >= f.bound
+	case "max":
+		return number(v) <= f.bound
+	case "minLength":
+		return length(v) >= f.length
+	case "maxLength":
+		return length(v) <= f.length
+	}
+	return true
+}
+
+func number(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+func length(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+	return v.Len()
+}
+
+// locate sets the path of node on a *FacetError that has none yet.
+func locate(err error, node gokoxml.Node) error {
+	if fe, ok := err.(*FacetError); ok && fe.Path == "" {
+		fe.Path = node.Path()
+	}
+	return err
+}
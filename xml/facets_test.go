@@ -0,0 +1,123 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const facetsTestString = `<Feed>
+  <Offer currency="EUR">
+    <Condition>New</Condition>
+    <TotalOffers>3</TotalOffers>
+    <Sku>AB-123</Sku>
+    <Title>Golxml</Title>
+  </Offer>
+  <Offer currency="usd">
+    <Condition>Broken</Condition>
+    <TotalOffers>-1</TotalOffers>
+    <Sku>123</Sku>
+    <Title>A title way too long</Title>
+  </Offer>
+</Feed>`
+
+type facetsTestOffer struct {
+	Currency    string `xml:"currency,attr,pattern=[A-Z]{3}"`
+	Condition   string `xml:",enum=New|Used|Refurbished"`
+	TotalOffers int    `xml:",min=0,max=100"`
+	Sku         string `xml:",pattern=[A-Z]+-[0-9]+"`
+	Title       string `xml:",minLength=2,maxLength=10"`
+}
+
+func (s *lXMLSuite) TestUnmarshalFacets(c *C) {
+	var v struct {
+		Offers []facetsTestOffer `xml:"Offer"`
+	}
+	err := Unmarshal([]byte(facetsTestString), &v)
+	c.Check(err, ErrorMatches, `xml: /Feed/Offer\[2\]/@currency: value "usd" violates pattern=\[A-Z\]\{3\}`)
+	c.Check(err, DeepEquals, &FacetError{"/Feed/Offer[2]/@currency", "pattern=[A-Z]{3}", "usd"})
+	c.Assert(v.Offers, HasLen, 1)
+	c.Check(v.Offers[0], Equals, facetsTestOffer{"EUR", "New", 3, "AB-123", "Golxml"})
+
+	// Each facet on its own.
+	fields := []interface{}{
+		&struct {
+			O []struct {
+				Condition string `xml:",enum=New|Used"`
+			} `xml:"Offer"`
+		}{},
+		&struct {
+			O []struct {
+				TotalOffers Optional[int8] `xml:",min=0"`
+			} `xml:"Offer"`
+		}{},
+		&struct {
+			O []struct {
+				TotalOffers *uint `xml:",max=2"`
+			} `xml:"Offer"`
+		}{},
+		&struct {
+			O []struct {
+				Sku string `xml:",pattern=[A-Z]+-[0-9]+"`
+			} `xml:"Offer"`
+		}{},
+		&struct {
+			O []struct {
+				Title []byte `xml:",maxLength=10"`
+			} `xml:"Offer"`
+		}{},
+	}
+	errs := []string{
+		`xml: /Feed/Offer\[2\]/Condition: value "Broken" violates enum=New\|Used`,
+		`xml: /Feed/Offer\[2\]/TotalOffers: value "-1" violates min=0`,
+		`xml: /Feed/Offer\[1\]/TotalOffers: value "3" violates max=2`,
+		`xml: /Feed/Offer\[2\]/Sku: value "123" violates pattern=.*`,
+		`xml: /Feed/Offer\[2\]/Title: value "A title way too long" violates maxLength=10`,
+	}
+	for i, v := range fields {
+		c.Check(Unmarshal([]byte(facetsTestString), v), ErrorMatches, errs[i])
+	}
+}
+
+func (s *lXMLSuite) TestUnmarshalFacetsInvalid(c *C) {
+	var v1 struct {
+		Title string `xml:",min=1"`
+	}
+	c.Check(Unmarshal([]byte(facetsTestString), &v1), ErrorMatches,
+		`xml: invalid facet in field Title of type .*: min on non-numeric type string`)
+
+	var v2 struct {
+		Title string `xml:",pattern=[a-"`
+	}
+	c.Check(Unmarshal([]byte(facetsTestString), &v2), ErrorMatches,
+		`xml: invalid option "pattern=\[a-" in field Title .*`)
+
+	var v3 struct {
+		Kind string `xml:",enum=a|b,default=c"`
+	}
+	c.Check(Unmarshal([]byte(facetsTestString), &v3), ErrorMatches,
+		`xml: invalid default in field Kind of type .*: xml: value "c" violates enum=a\|b`)
+}
+
+func (s *lXMLSuite) TestUnmarshalFacetsQuoted(c *C) {
+	const data = `<Offer currency="EUR"><Condition>Like New</Condition><Code>AB</Code></Offer>`
+	var v struct {
+		Currency  string `xml:"currency,attr,pattern='[A-Z]{1,3}'"`
+		Condition string `xmlopt:"enum=New|Like New|Used"`
+		Code      string `xml:",pattern='[A-Z]{2,3}',maxLength=3"`
+	}
+	c.Assert(Unmarshal([]byte(data), &v), IsNil)
+	c.Check(v.Currency, Equals, "EUR")
+	c.Check(v.Condition, Equals, "Like New")
+	c.Check(v.Code, Equals, "AB")
+
+	var w struct {
+		Condition string `xmlopt:"enum=New|Used"`
+		Code      string `xml:",pattern='[A-Z]{3,4}'"`
+	}
+	err := Unmarshal([]byte(data), &w)
+	c.Check(err, ErrorMatches, `xml: /Offer/(Condition: value "Like New" violates enum=New\|Used|Code: value "AB" violates pattern=\[A-Z\]\{3,4\})`)
+
+	var x struct {
+		Code string `xml:",pattern=[A-Z]{2,3}"`
+	}
+	c.Check(Unmarshal([]byte(data), &x), ErrorMatches, `xml: unknown flag "3}" in field Code .*`)
+}
@@ -0,0 +1,64 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"fmt"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+)
+
+// AfterUnmarshaler is implemented by types that normalise themselves
+// once their element is decoded.
+type AfterUnmarshaler interface {
+	AfterUnmarshalXML() error
+}
+
+// Validator is implemented by types that check their own invariants.
+type Validator interface {
+	Validate() error
+}
+
+var (
+	afterUnmarshalerType = reflect.TypeOf((*AfterUnmarshaler)(nil)).Elem()
+	validatorType        = reflect.TypeOf((*Validator)(nil)).Elem()
+)
+
+// A HookError is returned when the AfterUnmarshalXML or Validate method
+// of a struct fails.
+type HookError struct {
+	// Path is the XPath of the element of the struct and Line its
+	// line in the document.
+	Path string
+	Line int
+
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("xml: %s (line %d): %v", e.Path, e.Line, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// runHooks calls AfterUnmarshalXML and then Validate on the struct sv
+// decoded from start, as far as plan says its type has them. Both are
+// called on a pointer to sv, after the structs it contains. With
+// Decoder.Parallel, they may be called for several elements of a
+// slice at once.
+func (p *decodeState) runHooks(plan *decodePlan, sv reflect.Value, start gokoxml.Node) error {
+	v := sv.Addr().Interface()
+	if plan.after {
+		if err := v.(AfterUnmarshaler).AfterUnmarshalXML(); err != nil {
+			return &HookError{start.Path(), start.LineNumber(), err}
+		}
+	}
+	if plan.validate {
+		if err := v.(Validator).Validate(); err != nil {
+			return &HookError{start.Path(), start.LineNumber(), err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package xml
+
+import (
+	"errors"
+	. "launchpad.net/gocheck"
+	"strings"
+)
+
+var errHooksNoCurrency = errors.New("CurrencyCode required if Price set")
+
+type hooksTestPrice struct {
+	Amount       float64
+	CurrencyCode string
+}
+
+func (p *hooksTestPrice) AfterUnmarshalXML() error {
+	p.CurrencyCode = strings.ToUpper(strings.TrimSpace(p.CurrencyCode))
+	return nil
+}
+
+func (p hooksTestPrice) Validate() error {
+	if p.Amount != 0 && p.CurrencyCode == "" {
+		return errHooksNoCurrency
+	}
+	return nil
+}
+
+type hooksTestItem struct {
+	Price  hooksTestPrice
+	Prices int
+}
+
+// AfterUnmarshalXML runs after the hooks of the prices.
+func (i *hooksTestItem) AfterUnmarshalXML() error {
+	if i.Price.CurrencyCode != "" {
+		i.Prices++
+	}
+	return nil
+}
+
+func (s *lXMLSuite) TestUnmarshalHooks(c *C) {
+	var v struct {
+		Items []hooksTestItem `xml:"Item"`
+	}
+	data := `<Items>
+  <Item><Price><Amount>1</Amount><CurrencyCode> eur </CurrencyCode></Price></Item>
+  <Item><Price><Amount>0</Amount></Price></Item>
+</Items>`
+	c.Assert(Unmarshal([]byte(data), &v), IsNil)
+	c.Check(v.Items, DeepEquals, []hooksTestItem{{hooksTestPrice{1, "EUR"}, 1}, {hooksTestPrice{0, ""}, 0}})
+
+	data = `<Items>
+  <Item><Price><Amount>1</Amount><CurrencyCode>EUR</CurrencyCode></Price></Item>
+  <Item>
+    <Price><Amount>2</Amount></Price>
+  </Item>
+</Items>`
+	err := Unmarshal([]byte(data), &v)
+	c.Check(err, ErrorMatches, `xml: /Items/Item\[2\]/Price \(line 4\): CurrencyCode required if Price set`)
+	c.Check(errors.Is(err, errHooksNoCurrency), Equals, true)
+}
@@ -0,0 +1,49 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"context"
+)
+
+// UnmarshalHTML is like Unmarshal but parses data with the lenient
+// libxml2 HTML parser.
+func UnmarshalHTML(data []byte, v interface{}) error {
+	return new(Decoder).DecodeHTML(data, v)
+}
+
+// UnmarshalHTMLContext is like UnmarshalHTML but stops early once ctx
+// is done.
+func UnmarshalHTMLContext(ctx context.Context, data []byte, v interface{}) error {
+	return new(Decoder).DecodeHTMLContext(ctx, data, v)
+}
+
+// DecodeHTML is like Decode but parses data with the lenient libxml2
+// HTML parser, so real-world markup can be bound to v.
+//
+// The HTML parser lowercases element and attribute names, so tag paths
+// must be written in lowercase. Besides tag paths, fields may be bound
+// with `xpath:"..."` and `css:"..."` selectors, evaluated relative to
+// the element of the enclosing struct.
+func (d *Decoder) DecodeHTML(data []byte, v interface{}) error {
+	return d.DecodeHTMLContext(context.Background(), data, v)
+}
+
+// DecodeHTMLContext is like DecodeHTML but stops early once ctx is
+// done, as described for DecodeContext.
+func (d *Decoder) DecodeHTMLContext(ctx context.Context, data []byte, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.Limits.checkInput(data); err != nil {
+		return err
+	}
+
+	doc, err := parseHTML(ctx, data, &d.Limits)
+	if err != nil {
+		return err
+	}
+	return d.decode(ctx, doc, v)
+}
@@ -0,0 +1,73 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const htmlTestString = `<!DOCTYPE html>
+<html>
+<head><title>Golxml</title></head>
+<body>
+<h1>Results</h1>
+<ul class="nav">
+<li><a href="/one">One</a></li>
+<li><a href="/two">Two</a></li>
+</ul>
+<p>unclosed <br> paragraph
+</body>
+</html>`
+
+type htmlTestLink struct {
+	Href string `xpath:"@href"`
+	Text string `xml:",chardata"`
+}
+
+type htmlTestPage struct {
+	Title   string         `xml:"head>title"`
+	Heading string         `xpath:"//h1"`
+	Links   []htmlTestLink `css:"ul > li > a"`
+	First   *htmlTestLink  `css:"ul > li > a"`
+	Missing string         `xpath:"//table"`
+}
+
+func (s *lXMLSuite) TestUnmarshalHTML(c *C) {
+	var v htmlTestPage
+	c.Assert(UnmarshalHTML([]byte(htmlTestString), &v), IsNil)
+	c.Check(v, DeepEquals, htmlTestPage{
+		Title:   "Golxml",
+		Heading: "Results",
+		Links:   []htmlTestLink{{"/one", "One"}, {"/two", "Two"}},
+		First:   &htmlTestLink{"/one", "One"},
+	})
+}
+
+func (s *lXMLSuite) TestHTMLLimits(c *C) {
+	var v htmlTestPage
+	d := &Decoder{Limits: Limits{Depth: 4}}
+	c.Check(d.DecodeHTML([]byte(htmlTestString), &v), DeepEquals, &LimitError{"Depth", 4})
+
+	d = &Decoder{Limits: Limits{Depth: 5, Attributes: 1, TextLength: 11}}
+	c.Check(d.DecodeHTML([]byte(htmlTestString), &v), IsNil)
+}
+
+func (s *lXMLSuite) TestSelectorInXML(c *C) {
+	var v struct {
+		Values []string `xpath:"Items/*/Value"`
+		Before string   `xpath:"Before"`
+	}
+	c.Assert(Unmarshal([]byte(pathTestString), &v), IsNil)
+	c.Check(v.Values, DeepEquals, []string{"A", "B", "C", "D", "E"})
+	c.Check(v.Before, Equals, "1")
+}
+
+func (s *lXMLSuite) TestSelectorTagErrors(c *C) {
+	var v1 struct {
+		A string `xml:"a" xpath:"b"`
+	}
+	c.Check(Unmarshal([]byte(`<r/>`), &v1), ErrorMatches, "xml: selector combined with xml tag .*")
+
+	var v2 struct {
+		A string `xpath:"(("`
+	}
+	c.Check(Unmarshal([]byte(`<r/>`), &v2), ErrorMatches, "xml: invalid selector .*")
+}
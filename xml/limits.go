@@ -0,0 +1,72 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"fmt"
+)
+
+// Limits bounds the resources a single call to Decode may use. A zero
+// field means no limit.
+//
+// Depth, Elements, Attributes and TextLength are checked while the
+// input is parsed, which stops as soon as one is exceeded, and again
+// on the result of every transform.
+type Limits struct {
+	// InputBytes is the maximum size of the input document.
+	InputBytes int
+
+	// Depth is the maximum nesting depth of elements, the root
+	// element being at depth one.
+	Depth int
+
+	// Elements is the maximum number of elements in the document.
+	Elements int
+
+	// Attributes is the maximum number of attributes of one element.
+	Attributes int
+
+	// TextLength is the maximum length in bytes of a single text
+	// node or attribute value.
+	TextLength int
+
+	// SliceLength is the maximum number of elements decoded into a
+	// single slice.
+	SliceLength int
+}
+
+// A LimitError is returned when a document exceeds one of the Limits
+// of a Decoder.
+type LimitError struct {
+	// Limit is the name of the field of Limits that was exceeded.
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("xml: document exceeds limit %s of %d", e.Limit, e.Max)
+}
+
+// checkInput checks the size of data against l.
+func (l *Limits) checkInput(data []byte) error {
+	if l.InputBytes > 0 && len(data) > l.InputBytes {
+		return &LimitError{"InputBytes", l.InputBytes}
+	}
+	return nil
+}
+
+// checkSlice checks that a slice may grow to n elements.
+func (l *Limits) checkSlice(n int) error {
+	if l.SliceLength > 0 && n > l.SliceLength {
+		return &LimitError{"SliceLength", l.SliceLength}
+	}
+	return nil
+}
+
+// treeLimits tells whether l has limits that are checked on the
+// document tree.
+func (l *Limits) treeLimits() bool {
+	return l.Depth > 0 || l.Elements > 0 || l.Attributes > 0 || l.TextLength > 0
+}
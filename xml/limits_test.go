@@ -0,0 +1,63 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const limitsTestString = `<Result a="1" b="22">
+  <Items>
+    <Item><Value>first</Value></Item>
+    <Item><Value>second</Value></Item>
+    <Item><Value>third</Value></Item>
+  </Items>
+</Result>`
+
+type limitsTestResult struct {
+	A      int      `xml:"a,attr"`
+	Values []string `xml:"Items>Item>Value"`
+}
+
+var limitsTests = []struct {
+	limits Limits
+	limit  string
+}{
+	{Limits{InputBytes: 10}, "InputBytes"},
+	{Limits{Depth: 3}, "Depth"},
+	{Limits{Elements: 7}, "Elements"},
+	{Limits{Attributes: 1}, "Attributes"},
+	{Limits{TextLength: 5}, "TextLength"},
+	{Limits{SliceLength: 2}, "SliceLength"},
+}
+
+func (s *lXMLSuite) TestLimits(c *C) {
+	for _, t := range limitsTests {
+		d := &Decoder{Limits: t.limits}
+		var v limitsTestResult
+		err := d.Decode([]byte(limitsTestString), &v)
+		c.Assert(err, NotNil, Commentf("%+v", t.limits))
+		le, ok := err.(*LimitError)
+		c.Assert(ok, Equals, true, Commentf("%v", err))
+		c.Check(le.Limit, Equals, t.limit)
+	}
+}
+
+func (s *lXMLSuite) TestLimitsNotExceeded(c *C) {
+	d := &Decoder{Limits: Limits{
+		InputBytes:  len(limitsTestString),
+		Depth:       4,
+		Elements:    8,
+		Attributes:  2,
+		TextLength:  len("second"),
+		SliceLength: 3,
+	}}
+	var v limitsTestResult
+	c.Assert(d.Decode([]byte(limitsTestString), &v), IsNil)
+	c.Check(v, DeepEquals, limitsTestResult{1, []string{"first", "second", "third"}})
+}
+
+func (s *lXMLSuite) TestLimitsParallel(c *C) {
+	d := &Decoder{Parallel: 2, ParallelMin: 1, Limits: Limits{SliceLength: 2}}
+	var v limitsTestResult
+	err := d.Decode([]byte(limitsTestString), &v)
+	c.Check(err, DeepEquals, &LimitError{"SliceLength", 2})
+}
@@ -0,0 +1,92 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// A Mapping binds the fields of a struct type to XML for types that
+// cannot carry xml tags, such as generated or shared types. It maps
+// field names to what would be the value of their xml tag, for example
+// "Items>Item", "id,attr" or ",chardata". Fields missing from the
+// Mapping are treated as fields without tag.
+type Mapping map[string]string
+
+// mappingMap holds the registered mappings, guarded by tinfoLock.
+var mappingMap = make(map[reflect.Type]Mapping)
+
+// RegisterMapping registers m for the struct type of v, which may be a
+// value or a pointer. m replaces the xml tags of the type. It is
+// checked like tags are, and must be registered before the type is
+// first decoded, also as part of another type.
+func RegisterMapping(v interface{}, m Mapping) error {
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("xml: cannot map non-struct type %s", typ)
+	}
+	mapping := make(Mapping, len(m))
+	for name, tag := range m {
+		if f, ok := typ.FieldByName(name); !ok || len(f.Index) != 1 {
+			return fmt.Errorf("xml: mapping for unknown field %s of type %s", name, typ)
+		}
+		mapping[name] = tag
+	}
+
+	tinfoLock.Lock()
+	old, hadOld := mappingMap[typ]
+	mappingMap[typ] = mapping
+	delete(tinfoMap, typ)
+	tinfoLock.Unlock()
+	planLock.Lock()
+	for key := range planMap {
+		if key.typ == typ {
+			delete(planMap, key)
+		}
+	}
+	planLock.Unlock()
+
+	if _, err := getTypeInfo(typ); err != nil {
+		tinfoLock.Lock()
+		if hadOld {
+			mappingMap[typ] = old
+		} else {
+			delete(mappingMap, typ)
+		}
+		tinfoLock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// mappedField returns field i of the struct type typ, with the tag given
+// by the mapping of typ if it has one.
+func mappedField(typ reflect.Type, i int) reflect.StructField {
+	f := typ.Field(i)
+	tinfoLock.RLock()
+	m, ok := mappingMap[typ]
+	tinfoLock.RUnlock()
+	if ok {
+		f.Tag = reflect.StructTag("xml:" + strconv.Quote(m[f.Name]))
+	}
+	return f
+}
+
+// mappedFieldByIndex is like reflect.Type.FieldByIndex, but returns the
+// field with its mapped tag.
+func mappedFieldByIndex(typ reflect.Type, index []int) reflect.StructField {
+	for _, i := range index[:len(index)-1] {
+		typ = typ.Field(i).Type
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	return mappedField(typ, index[len(index)-1])
+}
@@ -0,0 +1,81 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"time"
+)
+
+// mappingTestOrder stands in for a generated type without xml tags.
+type mappingTestOrder struct {
+	Id       int64
+	Customer *mappingTestCustomer
+	Lines    []*mappingTestLine
+	Placed   time.Time
+	Comment  string
+	internal int
+}
+
+type mappingTestCustomer struct {
+	Name string
+	Vip  bool
+}
+
+type mappingTestLine struct {
+	Sku      string
+	Quantity int
+	Text     string
+}
+
+const mappingTestString = `<order id="42">
+  <customer vip="true"><name>Jane</name></customer>
+  <lines>
+    <line sku="a" qty="2">First</line>
+    <line sku="b" qty="1">Second</line>
+  </lines>
+  <placed>2012-06-01T10:00:00Z</placed>
+  <Comment>fast</Comment>
+</order>`
+
+func (s *lXMLSuite) TestRegisterMapping(c *C) {
+	c.Assert(RegisterMapping(mappingTestOrder{}, Mapping{
+		"Id":       "id,attr",
+		"Customer": "customer",
+		"Lines":    "lines>line",
+		"Placed":   "placed,layout=xs:dateTime",
+	}), IsNil)
+	c.Assert(RegisterMapping((*mappingTestCustomer)(nil), Mapping{
+		"Name": "name",
+		"Vip":  "vip,attr",
+	}), IsNil)
+	c.Assert(RegisterMapping(&mappingTestLine{}, Mapping{
+		"Sku":      "sku,attr",
+		"Quantity": "qty,attr",
+		"Text":     ",chardata",
+	}), IsNil)
+
+	var v mappingTestOrder
+	c.Assert(Unmarshal([]byte(mappingTestString), &v), IsNil)
+	c.Check(v.Id, Equals, int64(42))
+	c.Check(*v.Customer, Equals, mappingTestCustomer{"Jane", true})
+	c.Check(v.Lines, DeepEquals, []*mappingTestLine{{"a", 2, "First"}, {"b", 1, "Second"}})
+	c.Check(v.Placed.Equal(time.Date(2012, 6, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+	c.Check(v.Comment, Equals, "fast")
+}
+
+func (s *lXMLSuite) TestRegisterMappingErrors(c *C) {
+	type order struct {
+		Id, Ref string
+	}
+	c.Check(RegisterMapping(order{}, Mapping{"Number": "number"}), ErrorMatches,
+		"xml: mapping for unknown field Number of type xml.order")
+	c.Check(RegisterMapping(order{}, Mapping{"Id": "a>b", "Ref": "a>b"}), ErrorMatches,
+		`xml.order field "Id" with tag "a>b" conflicts with field "Ref" with tag "a>b"`)
+	c.Check(RegisterMapping(order{}, Mapping{"Id": "id,attr,chardata"}), ErrorMatches,
+		`xml: invalid tag in field Id of type xml.order: "id,attr,chardata"`)
+	c.Check(RegisterMapping(0, Mapping{}), ErrorMatches, "xml: cannot map non-struct type int")
+
+	// Failed registrations leave the type as it was.
+	var v order
+	c.Assert(Unmarshal([]byte(`<order><Id>1</Id></order>`), &v), IsNil)
+	c.Check(v.Id, Equals, "1")
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A NameMapper maps the Go names of fields without a name in their tag
+// to the names of their elements and attributes.
+type NameMapper struct {
+	fn func(string) string
+}
+
+// NewNameMapper returns a NameMapper applying fn to field names.
+//
+// The decoding plans of struct types are cached for each NameMapper
+// and never freed, so a NameMapper should be created once and reused,
+// like the predefined ones, rather than created for every Decoder.
+func NewNameMapper(fn func(string) string) *NameMapper {
+	return &NameMapper{fn}
+}
+
+// MapName returns the XML name for the field name.
+func (m *NameMapper) MapName(name string) string {
+	return m.fn(name)
+}
+
+// The predefined NameMappers. For the field name TotalHTTPOffers, they
+// give totalHttpOffers, total_http_offers and total-http-offers.
+var (
+	LowerCamelCase = NewNameMapper(lowerCamel)
+	SnakeCase      = NewNameMapper(func(name string) string { return joinWords(name, "_") })
+	KebabCase      = NewNameMapper(func(name string) string { return joinWords(name, "-") })
+)
+
+// words splits the Go name name into its words, keeping acronyms such
+// as HTTP together.
+func words(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		next := unicode.IsLower(safeRune(runes, i+1))
+		if unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+func safeRune(runes []rune, i int) rune {
+	if i < len(runes) {
+		return runes[i]
+	}
+	return 0
+}
+
+func joinWords(name, sep string) string {
+	return strings.ToLower(strings.Join(words(name), sep))
+}
+
+func lowerCamel(name string) string {
+	w := words(name)
+	for i := range w {
+		w[i] = strings.ToLower(w[i])
+		if i > 0 {
+			r := []rune(w[i])
+			r[0] = unicode.ToUpper(r[0])
+			w[i] = string(r)
+		}
+	}
+	return strings.Join(w, "")
+}
+
+// key returns the name of an element or attribute as used for lookups,
+// lower cased if matching is case insensitive.
+func (p *decodeState) key(name string) string {
+	if p.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
@@ -0,0 +1,73 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"strings"
+)
+
+type namesTestOffer struct {
+	OfferID     string `xml:",attr"`
+	TotalOffers int
+	HTTPURL     string
+	Price       float64 `xml:"amount"`
+	Tags        []string
+}
+
+type namesTestFeed struct {
+	Offers []namesTestOffer `xml:"Offers>Offer"`
+}
+
+func (s *lXMLSuite) TestNameMappers(c *C) {
+	names := []string{"TotalOffers", "HTTPURL", "OfferID", "Price2Go", "X", "ÄrgerNis"}
+	want := map[*NameMapper][]string{
+		LowerCamelCase: {"totalOffers", "httpurl", "offerId", "price2Go", "x", "ärgerNis"},
+		SnakeCase:      {"total_offers", "httpurl", "offer_id", "price2_go", "x", "ärger_nis"},
+		KebabCase:      {"total-offers", "httpurl", "offer-id", "price2-go", "x", "ärger-nis"},
+	}
+	for m, w := range want {
+		for i, name := range names {
+			c.Check(m.MapName(name), Equals, w[i])
+		}
+	}
+}
+
+func (s *lXMLSuite) TestDecodeNameMapper(c *C) {
+	data := `<feed><Offers>
+  <Offer offer-id="1"><total-offers>3</total-offers><httpurl>http://a</httpurl><amount>1.5</amount><tags>x</tags><tags>y</tags></Offer>
+</Offers></feed>`
+	var v namesTestFeed
+	d := &Decoder{NameMapper: KebabCase}
+	c.Assert(d.Decode([]byte(data), &v), IsNil)
+	c.Check(v.Offers, DeepEquals, []namesTestOffer{{"1", 3, "http://a", 1.5, []string{"x", "y"}}})
+
+	// The same type still decodes with its Go names without mapper.
+	data = `<feed><Offers><Offer OfferID="2"><TotalOffers>4</TotalOffers></Offer></Offers></feed>`
+	v = namesTestFeed{}
+	c.Assert(Unmarshal([]byte(data), &v), IsNil)
+	c.Check(v.Offers, DeepEquals, []namesTestOffer{{OfferID: "2", TotalOffers: 4}})
+
+	upper := NewNameMapper(strings.ToUpper)
+	data = `<feed><Offers><Offer OFFERID="3"><TOTALOFFERS>5</TOTALOFFERS></Offer></Offers></feed>`
+	v = namesTestFeed{}
+	c.Assert((&Decoder{NameMapper: upper}).Decode([]byte(data), &v), IsNil)
+	c.Check(v.Offers, DeepEquals, []namesTestOffer{{OfferID: "3", TotalOffers: 5}})
+}
+
+func (s *lXMLSuite) TestDecodeCaseInsensitive(c *C) {
+	data := `<FEED><offers>
+  <offer offerid="1"><TOTALOFFERS>3</TOTALOFFERS><Amount>2</Amount></offer>
+  <OFFER OfferId="2"><totalOffers>4</totalOffers></OFFER>
+</offers></FEED>`
+	var v namesTestFeed
+	d := &Decoder{CaseInsensitive: true}
+	c.Assert(d.Decode([]byte(data), &v), IsNil)
+	c.Check(v.Offers, DeepEquals, []namesTestOffer{{OfferID: "1", TotalOffers: 3, Price: 2}, {OfferID: "2", TotalOffers: 4}})
+
+	var r struct {
+		XMLName struct{} `xml:"Feed"`
+		Id      string   `xml:"id,attr,required"`
+	}
+	c.Check(d.Decode([]byte(`<FEED ID="x"/>`), &r), IsNil)
+	c.Check(r.Id, Equals, "x")
+	c.Check(Unmarshal([]byte(`<FEED ID="x"/>`), &r), ErrorMatches, "expected element type <Feed> but have <FEED>")
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+/*
+#cgo pkg-config: libxml-2.0
+#include <libxml/tree.h>
+
+// isNil tells whether node has an xsi:nil attribute that is true.
+static int isNil(xmlNodePtr node) {
+	xmlChar *v;
+	int ret;
+
+	if (node == NULL || node->type != XML_ELEMENT_NODE || node->properties == NULL) {
+		return 0;
+	}
+	v = xmlGetNsProp(node, BAD_CAST "nil",
+		BAD_CAST "http://www.w3.org/2001/XMLSchema-instance");
+	if (v == NULL) {
+		return 0;
+	}
+	ret = xmlStrEqual(v, BAD_CAST "true") || xmlStrEqual(v, BAD_CAST "1");
+	xmlFree(v);
+	return ret;
+}
+*/
+import "C"
+
+import (
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+)
+
+// Optional holds a value that may be missing from the document. It
+// tells an absent element or attribute, for which Present stays
+// false, from one that is present but empty, and from an element
+// marked with xsi:nil="true", for which Nil is set and Value stays
+// the zero value.
+type Optional[T any] struct {
+	Value   T
+	Present bool
+	Nil     bool
+}
+
+// optional is implemented by *Optional types.
+type optional interface {
+	// present records the value as present, and nil if isNil is set,
+	// and returns the value to decode into, if any.
+	present(isNil bool) reflect.Value
+}
+
+func (o *Optional[T]) present(isNil bool) reflect.Value {
+	o.Present, o.Nil = true, isNil
+	if isNil {
+		var zero T
+		o.Value = zero
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(&o.Value).Elem()
+}
+
+var optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+
+// isOptional tells whether typ is an Optional type.
+func isOptional(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && reflect.PtrTo(typ).Implements(optionalType)
+}
+
+// optionalElem returns the type of the value held by the Optional
+// type typ, or typ itself if it is none.
+func optionalElem(typ reflect.Type) reflect.Type {
+	if isOptional(typ) {
+		return typ.Field(0).Type
+	}
+	return typ
+}
+
+// isNil tells whether node is marked with xsi:nil="true".
+func isNil(node gokoxml.Node) bool {
+	return C.isNil(C.xmlNodePtr(node.NodePtr())) != 0
+}
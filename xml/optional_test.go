@@ -0,0 +1,61 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const optionalTestString = `<Update xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" id="7">
+  <Name xsi:nil="true"/>
+  <Email></Email>
+  <Age xsi:nil="1"/>
+  <Phone>555</Phone>
+  <Tag>a</Tag><Tag xsi:nil="true"/><Tag>c</Tag>
+  <Note xsi:nil="false">kept</Note>
+</Update>`
+
+func (s *lXMLSuite) TestUnmarshalNil(c *C) {
+	name, age := "old", 42
+	v := struct {
+		Name  *string
+		Email *string
+		Age   *int
+		Phone string
+		Tag   []*string
+		Note  string
+	}{Name: &name, Age: &age, Phone: "x"}
+	c.Assert(Unmarshal([]byte(optionalTestString), &v), IsNil)
+	c.Check(v.Name, IsNil)
+	c.Assert(v.Email, NotNil)
+	c.Check(*v.Email, Equals, "")
+	c.Check(v.Age, IsNil)
+	c.Check(v.Phone, Equals, "555")
+	c.Assert(v.Tag, HasLen, 3)
+	c.Check(*v.Tag[0], Equals, "a")
+	c.Check(v.Tag[1], IsNil)
+	c.Check(*v.Tag[2], Equals, "c")
+	c.Check(v.Note, Equals, "kept")
+}
+
+func (s *lXMLSuite) TestUnmarshalOptional(c *C) {
+	var v struct {
+		Id      Optional[int] `xml:"id,attr"`
+		Rev     Optional[int] `xml:"rev,attr"`
+		Name    Optional[string]
+		Email   Optional[string]
+		Age     Optional[int]
+		Phone   Optional[string]
+		Address Optional[string]
+		Tag     Optional[[]string]
+	}
+	v.Name.Value = "old"
+	c.Assert(Unmarshal([]byte(optionalTestString), &v), IsNil)
+	c.Check(v.Id, Equals, Optional[int]{Value: 7, Present: true})
+	c.Check(v.Rev, Equals, Optional[int]{})
+	c.Check(v.Name, Equals, Optional[string]{Present: true, Nil: true})
+	c.Check(v.Email, Equals, Optional[string]{Present: true})
+	c.Check(v.Age, Equals, Optional[int]{Present: true, Nil: true})
+	c.Check(v.Phone, Equals, Optional[string]{Value: "555", Present: true})
+	c.Check(v.Address, Equals, Optional[string]{})
+	c.Check(v.Tag.Present, Equals, true)
+	c.Check(v.Tag.Value, DeepEquals, []string{"a", "", "c"})
+}
@@ -0,0 +1,136 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// A sliceBatch collects the elements of the slice fields of a struct,
+// so they can be decoded together once the struct's element is done.
+type sliceBatch struct {
+	fields []batchField
+}
+
+type batchField struct {
+	finfo *fieldInfo
+	nodes []gokoxml.Node
+}
+
+// add appends node to the elements collected for finfo.
+func (b *sliceBatch) add(finfo *fieldInfo, node gokoxml.Node) {
+	for i := range b.fields {
+		if b.fields[i].finfo == finfo {
+			b.fields[i].nodes = append(b.fields[i].nodes, node)
+			return
+		}
+	}
+	b.fields = append(b.fields, batchField{finfo, []gokoxml.Node{node}})
+}
+
+// unmarshalBatch decodes the elements collected in batch into the
+// fields of sv, in parallel for the fields with enough elements.
+func (p *decodeState) unmarshalBatch(sv reflect.Value, batch *sliceBatch) error {
+	min := p.ParallelMin
+	if min <= 0 {
+		min = DefaultParallelMin
+	}
+
+	for _, bf := range batch.fields {
+		fv := sv.FieldByIndex(bf.finfo.idx)
+		if len(bf.nodes) >= min {
+			if err := p.unmarshalParallel(fv, bf.nodes, bf.finfo); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, node := range bf.nodes {
+			if err := p.unmarshal(fv, node, bf.finfo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalParallel appends the values of nodes to the slice v of the
+// field finfo. The calling goroutine is helped by as many goroutines as
+// the workers of p allow, which are shared with nested slices, so a
+// decode never runs more than p.Parallel goroutines. On error, no
+// further elements are started and v keeps the elements before the
+// first element that failed, as it would when decoding serially.
+func (p *decodeState) unmarshalParallel(v reflect.Value, nodes []gokoxml.Node, finfo *fieldInfo) error {
+	// Grow slice to its final length, every goroutine then fills in
+	// elements of its own.
+	n := v.Len()
+	if err := p.Limits.checkSlice(n + len(nodes)); err != nil {
+		return err
+	}
+	if n+len(nodes) > v.Cap() {
+		new := reflect.MakeSlice(v.Type(), n, n+len(nodes))
+		reflect.Copy(new, v)
+		v.Set(new)
+	}
+	v.SetLen(n + len(nodes))
+
+	var (
+		next   int64 = -1
+		failed       = int64(len(nodes)) // lowest index of an element that failed
+		errs         = make([]error, len(nodes))
+		wg     sync.WaitGroup
+	)
+	work := func() {
+		for {
+			// Elements are taken in order, so once one fails the
+			// following ones are not needed.
+			i := atomic.AddInt64(&next, 1)
+			if i >= int64(len(nodes)) || i > atomic.LoadInt64(&failed) {
+				return
+			}
+			if errs[i] = p.unmarshal(v.Index(n+int(i)), nodes[i], finfo); errs[i] != nil {
+				for f := atomic.LoadInt64(&failed); i < f; f = atomic.LoadInt64(&failed) {
+					if atomic.CompareAndSwapInt64(&failed, f, i) {
+						break
+					}
+				}
+			}
+		}
+	}
+	for w := 1; w < len(nodes) && p.acquireWorker(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.releaseWorker()
+			work()
+		}()
+	}
+	work()
+	wg.Wait()
+
+	if i := failed; i < int64(len(nodes)) {
+		v.SetLen(n + int(i))
+		return errs[i]
+	}
+	return nil
+}
+
+// acquireWorker tells whether another goroutine may be started for a
+// parallel decode, and reserves it if so.
+func (p *decodeState) acquireWorker() bool {
+	select {
+	case p.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseWorker gives back a goroutine reserved by acquireWorker.
+func (p *decodeState) releaseWorker() {
+	<-p.workers
+}
@@ -0,0 +1,134 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	. "launchpad.net/gocheck"
+	"sync/atomic"
+	"time"
+)
+
+type parallelTestItem struct {
+	Id    int `xml:"id,attr"`
+	Count int
+	Value string `xml:"Value"`
+	Tags  []string
+	Again string `xpath:"Value"`
+}
+
+type parallelTestResult struct {
+	Items []*parallelTestItem `xml:"Items>Item"`
+	Other []string            `xml:"Other"`
+}
+
+func parallelTestData(n int, bad int) []byte {
+	var b bytes.Buffer
+	b.WriteString("<Result><Items>")
+	for i := 0; i < n; i++ {
+		count := fmt.Sprint(i * 2)
+		if i == bad {
+			count = "bad"
+		}
+		fmt.Fprintf(&b, `<Item id="%d"><Count>%s</Count><Value>v%d</Value><Tags>a%d</Tags><Tags>b%d</Tags></Item>`, i, count, i, i, i)
+	}
+	b.WriteString("</Items><Other>x</Other><Other>y</Other></Result>")
+	return b.Bytes()
+}
+
+func (s *lXMLSuite) TestDecodeParallel(c *C) {
+	data := parallelTestData(500, -1)
+
+	var want, got parallelTestResult
+	c.Assert(Unmarshal(data, &want), IsNil)
+	c.Assert(want.Items, HasLen, 500)
+	c.Assert(want.Items[499].Tags, DeepEquals, []string{"a499", "b499"})
+	c.Assert(want.Items[499].Again, Equals, "v499")
+
+	d := &Decoder{Parallel: 4, ParallelMin: 10}
+	c.Assert(d.Decode(data, &got), IsNil)
+	c.Check(got, DeepEquals, want)
+}
+
+func (s *lXMLSuite) TestDecodeParallelError(c *C) {
+	d := &Decoder{Parallel: 4, ParallelMin: 10}
+
+	var v struct {
+		Items []parallelTestItem `xml:"Items>Item"`
+	}
+	v.Items = []parallelTestItem{{Id: -1}}
+	c.Check(d.Decode(parallelTestData(100, 42), &v), NotNil)
+	c.Assert(v.Items, HasLen, 43)
+	c.Check(v.Items[0].Id, Equals, -1)
+	c.Check(v.Items[42].Count, Equals, 82)
+}
+
+// parallelTestHooked records how many of its values are decoded, and
+// how many at most at the same time.
+type parallelTestHooked struct {
+	Count int
+}
+
+var parallelTestActive, parallelTestMax, parallelTestDone int32
+
+func (h *parallelTestHooked) AfterUnmarshalXML() error {
+	n := atomic.AddInt32(&parallelTestActive, 1)
+	for m := atomic.LoadInt32(&parallelTestMax); n > m; m = atomic.LoadInt32(&parallelTestMax) {
+		if atomic.CompareAndSwapInt32(&parallelTestMax, m, n) {
+			break
+		}
+	}
+	time.Sleep(100 * time.Microsecond)
+	atomic.AddInt32(&parallelTestActive, -1)
+	atomic.AddInt32(&parallelTestDone, 1)
+	return nil
+}
+
+type parallelTestNested struct {
+	Groups []struct {
+		Items []parallelTestHooked `xml:"Item"`
+	} `xml:"Group"`
+}
+
+// parallelTestGroups returns groups of n elements each, the first
+// element of the group bad having an invalid count.
+func parallelTestGroups(groups, n, bad int) []byte {
+	var b bytes.Buffer
+	b.WriteString("<Result>")
+	for g := 0; g < groups; g++ {
+		b.WriteString("<Group>")
+		for i := 0; i < n; i++ {
+			count := fmt.Sprint(i)
+			if g == bad && i == 0 {
+				count = "bad"
+			}
+			fmt.Fprintf(&b, "<Item><Count>%s</Count></Item>", count)
+		}
+		b.WriteString("</Group>")
+	}
+	b.WriteString("</Result>")
+	return b.Bytes()
+}
+
+func (s *lXMLSuite) TestDecodeParallelNested(c *C) {
+	d := &Decoder{Parallel: 3, ParallelMin: 10}
+	atomic.StoreInt32(&parallelTestMax, 0)
+	atomic.StoreInt32(&parallelTestDone, 0)
+
+	var v parallelTestNested
+	c.Assert(d.Decode(parallelTestGroups(20, 50, -1), &v), IsNil)
+	c.Assert(v.Groups, HasLen, 20)
+	c.Check(v.Groups[19].Items[49].Count, Equals, 49)
+	c.Check(atomic.LoadInt32(&parallelTestDone), Equals, int32(1000))
+	c.Check(atomic.LoadInt32(&parallelTestMax) <= 3, Equals, true)
+}
+
+func (s *lXMLSuite) TestDecodeParallelNestedError(c *C) {
+	d := &Decoder{Parallel: 3, ParallelMin: 10}
+	atomic.StoreInt32(&parallelTestDone, 0)
+
+	var v parallelTestNested
+	c.Check(d.Decode(parallelTestGroups(20, 50, 1), &v), ErrorMatches, `strconv.ParseInt: .*`)
+	c.Check(v.Groups, HasLen, 1)
+	// Groups after the failed one are not started once it fails.
+	c.Check(atomic.LoadInt32(&parallelTestDone) < 500, Equals, true)
+}
@@ -0,0 +1,477 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+/*
+#cgo pkg-config: libxml-2.0
+#include <stdlib.h>
+#include <libxml/parser.h>
+#include <libxml/parserInternals.h>
+#include <libxml/HTMLparser.h>
+#include <libxml/SAX2.h>
+
+// resetPush prepares the parser ctxt to parse a new document pushed
+// to it in chunks.
+static void resetPush(xmlParserCtxtPtr ctxt, int options) {
+	xmlCtxtResetPush(ctxt, NULL, 0, NULL, "utf-8");
+	xmlCtxtUseOptions(ctxt, options);
+}
+
+static int parseChunk(xmlParserCtxtPtr ctxt, void *buf, int size, int terminate) {
+	if (ctxt->html) {
+		return htmlParseChunk(ctxt, buf, size, terminate);
+	}
+	return xmlParseChunk(ctxt, buf, size, terminate);
+}
+
+// takeDoc returns the document parsed by ctxt, or NULL if it is not
+// well-formed and the parser does not recover from errors.
+static xmlDocPtr takeDoc(xmlParserCtxtPtr ctxt) {
+	xmlDocPtr doc = ctxt->myDoc;
+
+	ctxt->myDoc = NULL;
+	if (doc != NULL && !ctxt->html && !ctxt->wellFormed && !ctxt->recovery) {
+		xmlFreeDoc(doc);
+		doc = NULL;
+	}
+	return doc;
+}
+
+static const char *lastError(xmlParserCtxtPtr ctxt) {
+	return ctxt->lastError.message;
+}
+
+enum {
+	limitNone = -1,
+	limitDepth,
+	limitElements,
+	limitAttributes,
+	limitTextLength
+};
+
+// limitState holds the limits of a parse and the counts checked
+// against them. It is the _private data of the parser context.
+typedef struct {
+	long maxDepth, maxElements, maxAttributes, maxTextLength;
+	long depth, elements, text;
+	int textType, exceeded;
+} limitState;
+
+static void resetLimits(xmlParserCtxtPtr ctxt, long maxDepth, long maxElements,
+		long maxAttributes, long maxTextLength) {
+	limitState *s = ctxt->_private;
+
+	s->maxDepth = maxDepth;
+	s->maxElements = maxElements;
+	s->maxAttributes = maxAttributes;
+	s->maxTextLength = maxTextLength;
+	s->depth = s->elements = s->text = 0;
+	s->textType = 0;
+	s->exceeded = limitNone;
+}
+
+static int exceededLimit(xmlParserCtxtPtr ctxt) {
+	return ((limitState *)ctxt->_private)->exceeded;
+}
+
+// exceed stops the parser on the limit it exceeds.
+static void exceed(xmlParserCtxtPtr ctxt, int limit) {
+	limitState *s = ctxt->_private;
+
+	if (s->exceeded == limitNone) {
+		s->exceeded = limit;
+	}
+	xmlStopParser(ctxt);
+}
+
+// enterElement counts an element with n attributes and tells whether
+// it is within the limits.
+static int enterElement(xmlParserCtxtPtr ctxt, int n) {
+	limitState *s = ctxt->_private;
+
+	s->text = 0;
+	if (s->maxDepth > 0 && ++s->depth > s->maxDepth) {
+		exceed(ctxt, limitDepth);
+		return 0;
+	}
+	if (s->maxElements > 0 && ++s->elements > s->maxElements) {
+		exceed(ctxt, limitElements);
+		return 0;
+	}
+	if (s->maxAttributes > 0 && n > s->maxAttributes) {
+		exceed(ctxt, limitAttributes);
+		return 0;
+	}
+	return 1;
+}
+
+static void leaveElement(xmlParserCtxtPtr ctxt) {
+	limitState *s = ctxt->_private;
+
+	s->text = 0;
+	if (s->maxDepth > 0) {
+		s->depth--;
+	}
+}
+
+// checkText adds n bytes to the text node of type typ being parsed
+// and tells whether it is within the limits.
+static int checkText(xmlParserCtxtPtr ctxt, int typ, long n) {
+	limitState *s = ctxt->_private;
+
+	if (s->maxTextLength <= 0) {
+		return 1;
+	}
+	if (s->textType != typ) {
+		s->textType = typ;
+		s->text = 0;
+	}
+	if ((s->text += n) > s->maxTextLength) {
+		exceed(ctxt, limitTextLength);
+		return 0;
+	}
+	return 1;
+}
+
+static void limitStartElementNs(void *ctx, const xmlChar *localname,
+		const xmlChar *prefix, const xmlChar *URI, int nb_namespaces,
+		const xmlChar **namespaces, int nb_attributes, int nb_defaulted,
+		const xmlChar **attributes) {
+	xmlParserCtxtPtr ctxt = ctx;
+	int i;
+
+	if (!enterElement(ctxt, nb_attributes)) {
+		return;
+	}
+	for (i = 0; i < nb_attributes; i++) {
+		((limitState *)ctxt->_private)->textType = 0;
+		if (!checkText(ctxt, XML_ATTRIBUTE_NODE, attributes[i*5+4] - attributes[i*5+3])) {
+			return;
+		}
+	}
+	((limitState *)ctxt->_private)->text = 0;
+	xmlSAX2StartElementNs(ctx, localname, prefix, URI, nb_namespaces,
+		namespaces, nb_attributes, nb_defaulted, attributes);
+}
+
+static void limitEndElementNs(void *ctx, const xmlChar *localname,
+		const xmlChar *prefix, const xmlChar *URI) {
+	leaveElement(ctx);
+	xmlSAX2EndElementNs(ctx, localname, prefix, URI);
+}
+
+// limitStartElement is used by the HTML parser, atts holds pairs of
+// names and values.
+static void limitStartElement(void *ctx, const xmlChar *name, const xmlChar **atts) {
+	xmlParserCtxtPtr ctxt = ctx;
+	int i, n = 0;
+
+	for (i = 0; atts != NULL && atts[i] != NULL; i += 2) {
+		n++;
+	}
+	if (!enterElement(ctxt, n)) {
+		return;
+	}
+	for (i = 0; atts != NULL && atts[i] != NULL; i += 2) {
+		((limitState *)ctxt->_private)->textType = 0;
+		if (atts[i+1] != NULL && !checkText(ctxt, XML_ATTRIBUTE_NODE, xmlStrlen(atts[i+1]))) {
+			return;
+		}
+	}
+	((limitState *)ctxt->_private)->text = 0;
+	xmlSAX2StartElement(ctx, name, atts);
+}
+
+static void limitEndElement(void *ctx, const xmlChar *name) {
+	leaveElement(ctx);
+	xmlSAX2EndElement(ctx, name);
+}
+
+static void limitCharacters(void *ctx, const xmlChar *ch, int len) {
+	if (checkText(ctx, XML_TEXT_NODE, len)) {
+		xmlSAX2Characters(ctx, ch, len);
+	}
+}
+
+static void limitCDataBlock(void *ctx, const xmlChar *value, int len) {
+	if (checkText(ctx, XML_CDATA_SECTION_NODE, len)) {
+		xmlSAX2CDataBlock(ctx, value, len);
+	}
+}
+
+static void limitComment(void *ctx, const xmlChar *value) {
+	((limitState *)((xmlParserCtxtPtr)ctx)->_private)->textType = 0;
+	xmlSAX2Comment(ctx, value);
+}
+
+static void limitProcessingInstruction(void *ctx, const xmlChar *target, const xmlChar *data) {
+	((limitState *)((xmlParserCtxtPtr)ctx)->_private)->textType = 0;
+	xmlSAX2ProcessingInstruction(ctx, target, data);
+}
+
+// installLimits makes the parser ctxt, with a default SAX2 handler,
+// check the limits set by resetLimits while it builds the tree.
+static int installLimits(xmlParserCtxtPtr ctxt) {
+	xmlSAXHandlerPtr sax = ctxt->sax;
+
+	if ((ctxt->_private = calloc(1, sizeof(limitState))) == NULL) {
+		return 0;
+	}
+	if (sax->startElementNs == xmlSAX2StartElementNs) {
+		sax->startElementNs = limitStartElementNs;
+		sax->endElementNs = limitEndElementNs;
+	}
+	if (sax->startElement == xmlSAX2StartElement) {
+		sax->startElement = limitStartElement;
+		sax->endElement = limitEndElement;
+	}
+	if (sax->ignorableWhitespace == sax->characters) {
+		sax->ignorableWhitespace = limitCharacters;
+	}
+	sax->characters = limitCharacters;
+	sax->cdataBlock = limitCDataBlock;
+	sax->comment = limitComment;
+	sax->processingInstruction = limitProcessingInstruction;
+	return 1;
+}
+
+static void freeLimits(xmlParserCtxtPtr ctxt) {
+	free(ctxt->_private);
+	ctxt->_private = NULL;
+}
+
+// newHTMLCtxt returns a push parser context for HTML that checks the
+// limits. There is no way to reset one for a new document.
+static htmlParserCtxtPtr newHTMLCtxt(void) {
+	htmlParserCtxtPtr ctxt = htmlCreatePushParserCtxt(NULL, NULL, NULL, 0, NULL, XML_CHAR_ENCODING_UTF8);
+
+	if (ctxt == NULL) {
+		return NULL;
+	}
+	htmlCtxtUseOptions(ctxt, HTML_PARSE_RECOVER | HTML_PARSE_NONET | HTML_PARSE_NOERROR | HTML_PARSE_NOWARNING);
+	if (!installLimits(ctxt)) {
+		htmlFreeParserCtxt(ctxt);
+		return NULL;
+	}
+	return ctxt;
+}
+
+static void freeHTMLCtxt(htmlParserCtxtPtr ctxt) {
+	freeLimits(ctxt);
+	htmlFreeParserCtxt(ctxt);
+}
+
+static int textTooLong(xmlNodePtr node, long max) {
+	return max > 0 && node != NULL && node->content != NULL &&
+		xmlStrlen(node->content) > max;
+}
+
+// checkTree walks the tree below root in document order and returns the
+// limit it exceeds, if any.
+static int checkTree(xmlNodePtr root, long maxDepth, long maxElements,
+		long maxAttributes, long maxTextLength) {
+	xmlNodePtr cur = root;
+	xmlAttrPtr attr;
+	long depth = 1, elements = 0, n;
+
+	while (cur != NULL) {
+		switch (cur->type) {
+		case XML_ELEMENT_NODE:
+			if (maxDepth > 0 && depth > maxDepth) {
+				return limitDepth;
+			}
+			if (maxElements > 0 && ++elements > maxElements) {
+				return limitElements;
+			}
+			for (attr = cur->properties, n = 0; attr != NULL; attr = attr->next) {
+				if (maxAttributes > 0 && ++n > maxAttributes) {
+					return limitAttributes;
+				}
+				if (textTooLong(attr->children, maxTextLength)) {
+					return limitTextLength;
+				}
+			}
+			if (cur->children != NULL) {
+				cur = cur->children;
+				depth++;
+				continue;
+			}
+			break;
+		case XML_TEXT_NODE:
+		case XML_CDATA_SECTION_NODE:
+			if (textTooLong(cur, maxTextLength)) {
+				return limitTextLength;
+			}
+			break;
+		default:
+			break;
+		}
+
+		while (cur != root && cur->next == NULL) {
+			cur = cur->parent;
+			depth--;
+		}
+		if (cur == root) {
+			break;
+		}
+		cur = cur->next;
+	}
+	return limitNone;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+func init() {
+	// Must run once before parsers are used from several goroutines.
+	C.xmlInitParser()
+}
+
+// parserCtxt wraps a libxml2 parser context. Contexts are reset by
+// every parse, so they are pooled instead of being created per call.
+type parserCtxt struct {
+	ptr C.xmlParserCtxtPtr
+}
+
+var parserPool = sync.Pool{New: newParserCtxt}
+
+func newParserCtxt() interface{} {
+	ptr := C.xmlNewParserCtxt()
+	if ptr == nil {
+		return nil
+	}
+	if C.installLimits(ptr) == 0 {
+		C.xmlFreeParserCtxt(ptr)
+		return nil
+	}
+	ctxt := &parserCtxt{ptr: ptr}
+	// The pool may drop contexts at any time, free them along with
+	// their Go wrapper.
+	runtime.SetFinalizer(ctxt, func(ctxt *parserCtxt) {
+		C.freeLimits(ctxt.ptr)
+		C.xmlFreeParserCtxt(ctxt.ptr)
+	})
+	return ctxt
+}
+
+// parseChunkSize is the number of bytes parsed between two checks of
+// the context of a parse.
+const parseChunkSize = 64 << 10
+
+// testHookParseChunk, if not nil, is called after every chunk parsed.
+var testHookParseChunk func()
+
+// parseXML parses data with a pooled parser context. It stops as soon
+// as ctx is done or the document exceeds the tree limits in l.
+func parseXML(ctx context.Context, data []byte, opts gokoxml.ParseOption, l *Limits) (*gokoxml.XmlDocument, error) {
+	if len(data) == 0 {
+		return nil, errors.New("xml: empty document")
+	}
+
+	ctxt, _ := parserPool.Get().(*parserCtxt)
+	if ctxt == nil {
+		return nil, errors.New("xml: cannot allocate parser context")
+	}
+	defer parserPool.Put(ctxt)
+
+	// Documents must not share the dictionary of the context, it is
+	// handed on to the next parse while the document is still in use.
+	opts |= gokoxml.XML_PARSE_NODICT
+	C.resetPush(ctxt.ptr, C.int(opts))
+	return parse(ctx, ctxt.ptr, data, l)
+}
+
+// parseHTML is like parseXML for the lenient libxml2 HTML parser.
+func parseHTML(ctx context.Context, data []byte, l *Limits) (*gokoxml.XmlDocument, error) {
+	if len(data) == 0 {
+		return nil, errors.New("xml: empty document")
+	}
+
+	ptr := C.newHTMLCtxt()
+	if ptr == nil {
+		return nil, errors.New("xml: cannot allocate parser context")
+	}
+	defer C.freeHTMLCtxt(ptr)
+	return parse(ctx, C.xmlParserCtxtPtr(ptr), data, l)
+}
+
+// parse pushes data to ctxt in chunks of parseChunkSize, checking ctx
+// and the limits in between.
+func parse(ctx context.Context, ctxt C.xmlParserCtxtPtr, data []byte, l *Limits) (*gokoxml.XmlDocument, error) {
+	C.resetLimits(ctxt, C.long(l.Depth), C.long(l.Elements), C.long(l.Attributes), C.long(l.TextLength))
+	for off := 0; off < len(data); {
+		n := len(data) - off
+		if n > parseChunkSize {
+			n = parseChunkSize
+		}
+		var terminate C.int
+		if off+n == len(data) {
+			terminate = 1
+		}
+		C.parseChunk(ctxt, unsafe.Pointer(&data[off]), C.int(n), terminate)
+		off += n
+
+		if testHookParseChunk != nil {
+			testHookParseChunk()
+		}
+		if ctx.Err() != nil || C.exceededLimit(ctxt) != C.limitNone {
+			break
+		}
+	}
+
+	// A document left by a parse that was stopped is of no use.
+	ptr := C.takeDoc(ctxt)
+	err := ctx.Err()
+	if err == nil {
+		err = limitError(C.exceededLimit(ctxt), l)
+	}
+	if err != nil {
+		if ptr != nil {
+			C.xmlFreeDoc(ptr)
+		}
+		return nil, err
+	}
+	if ptr == nil {
+		msg := "failed to parse input"
+		if cmsg := C.lastError(ctxt); cmsg != nil {
+			msg = strings.TrimSpace(C.GoString(cmsg))
+		}
+		return nil, errors.New("xml: " + msg)
+	}
+	return gokoxml.NewDocument(unsafe.Pointer(ptr), len(data), gokoxml.DefaultEncodingBytes, gokoxml.DefaultEncodingBytes), nil
+}
+
+// checkTree checks the tree of doc against the limits in l. Parsed
+// documents are checked while parsing, this is for the results of
+// transforms.
+func checkTree(doc *gokoxml.XmlDocument, l *Limits) error {
+	root := C.xmlNodePtr(doc.Root().NodePtr())
+	return limitError(C.checkTree(root, C.long(l.Depth), C.long(l.Elements), C.long(l.Attributes), C.long(l.TextLength)), l)
+}
+
+// limitError returns the error for the limit of l named by the C
+// result limit.
+func limitError(limit C.int, l *Limits) error {
+	switch limit {
+	case C.limitDepth:
+		return &LimitError{"Depth", l.Depth}
+	case C.limitElements:
+		return &LimitError{"Elements", l.Elements}
+	case C.limitAttributes:
+		return &LimitError{"Attributes", l.Attributes}
+	case C.limitTextLength:
+		return &LimitError{"TextLength", l.TextLength}
+	}
+	return nil
+}
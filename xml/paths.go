@@ -0,0 +1,225 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"strconv"
+	"strings"
+)
+
+// A step of a field path is an element name, alternative names
+// separated by "|", or "*" for any element. An empty step stands for
+// any number of levels of elements, written as ">>" in the tag. The
+// last step of an attribute field names the attribute, the others the
+// elements leading to the element holding it.
+//
+// A step with a single name may end in a predicate in brackets: an
+// index among the elements of that name, counted from the end if
+// negative, or @name=value to select elements by an attribute.
+
+// stepMatches tells whether the path step step matches the name name.
+// Predicates are not looked at.
+func stepMatches(step, name string) bool {
+	step, _ = splitPredicate(step)
+	if step == "*" || step == name {
+		return true
+	}
+	for step != "" {
+		alt := step
+		if i := strings.IndexByte(step, '|'); i >= 0 {
+			alt, step = step[:i], step[i+1:]
+		} else {
+			step = ""
+		}
+		if alt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stepsOverlap tells whether some name matches both steps a and b.
+// Steps with predicates lead to paths of their own, so they only
+// overlap with the very same step.
+func stepsOverlap(a, b string) bool {
+	if _, pa := splitPredicate(a); pa != "" {
+		return a == b
+	}
+	if _, pb := splitPredicate(b); pb != "" {
+		return false
+	}
+	if a == "*" || b == "*" {
+		return true
+	}
+	for _, alt := range strings.Split(a, "|") {
+		if stepMatches(b, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathsOverlap tells whether an element could be matched by both paths
+// a and b, or by a and a prefix of b, or the other way round. Paths
+// only running through the element matched by the other one within a
+// descendant step do not overlap, such elements are found by both.
+func pathsOverlap(a, b []string) bool {
+	return matchSteps(a, b, true) || matchSteps(b, a, true)
+}
+
+// matchSteps tells whether some list of elements matched by the steps
+// a has a prefix matched by the steps b, or, if prefix is false, is
+// matched by b as a whole. A descendant step may end or match one
+// more element at each point, but not the last one matched by b.
+func matchSteps(a, b []string, prefix bool) bool {
+	switch {
+	case len(b) == 0:
+		return prefix || len(a) == 0
+	case len(a) == 0:
+		return false
+	case a[0] == "" && b[0] != "":
+		return matchSteps(a[1:], b, prefix) || len(b) > 1 && matchSteps(a, b[1:], prefix)
+	case a[0] == "" || b[0] == "":
+		return matchSteps(a[1:], b, prefix) || matchSteps(a, b[1:], prefix)
+	}
+	return stepsOverlap(a[0], b[0]) && matchSteps(a[1:], b[1:], prefix)
+}
+
+// validSteps checks the steps of a field path.
+func validSteps(steps []string) bool {
+	for i, step := range steps {
+		if step == "" {
+			// Descendant steps cannot start, end or follow each other.
+			if i == 0 || i == len(steps)-1 || steps[i-1] == "" {
+				return false
+			}
+			continue
+		}
+		name, pred := splitPredicate(step)
+		if pred != "" {
+			// Predicates go on single names.
+			if _, err := parsePredicate(pred); err != nil || strings.ContainsAny(name, "|*") {
+				return false
+			}
+		}
+		if strings.ContainsAny(name, "[]") {
+			return false
+		}
+		if name != "*" {
+			for _, alt := range strings.Split(name, "|") {
+				if alt == "" || alt == "*" {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// validAttrSteps checks the steps of the path of an attribute field.
+// The name must be plain, and the element must be named.
+func validAttrSteps(steps []string) bool {
+	name := steps[len(steps)-1]
+	if name == "" || name == "*" || strings.ContainsAny(name, "[]") {
+		return false
+	}
+	return len(steps) == 1 || steps[len(steps)-2] != ""
+}
+
+// path returns the steps of the path of finfo.
+func (finfo *fieldInfo) path() []string {
+	return append(finfo.parents[:len(finfo.parents):len(finfo.parents)], finfo.name)
+}
+
+// A predicate selects the elements matched by a step of a path.
+type predicate struct {
+	// index is the position of the element among its siblings of
+	// the same name, from the end if negative.
+	index int
+
+	// attr and value select elements with attribute attr set to
+	// value, if attr is not empty.
+	attr, value string
+}
+
+// splitPredicate splits step into its names and the text of its
+// predicate, if it has one.
+func splitPredicate(step string) (string, string) {
+	if i := strings.IndexByte(step, '['); i >= 0 && strings.HasSuffix(step, "]") {
+		return step[:i], step[i+1 : len(step)-1]
+	}
+	return step, ""
+}
+
+// parsePredicate parses the text of a predicate, either an index or
+// @name=value. value may be quoted.
+func parsePredicate(text string) (*predicate, error) {
+	if !strings.HasPrefix(text, "@") {
+		i, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, err
+		}
+		return &predicate{index: i}, nil
+	}
+	i := strings.IndexByte(text, '=')
+	if i < 2 {
+		return nil, errors.New("invalid predicate")
+	}
+	value := text[i+1:]
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return &predicate{attr: text[1:i], value: value}, nil
+}
+
+// foldStep lower cases the names of step, leaving the values in its
+// predicate alone.
+func foldStep(step string) string {
+	names, pred := splitPredicate(step)
+	if pred == "" {
+		return strings.ToLower(names)
+	}
+	if i := strings.IndexByte(pred, '='); strings.HasPrefix(pred, "@") && i >= 0 {
+		pred = strings.ToLower(pred[:i]) + pred[i:]
+	}
+	return strings.ToLower(names) + "[" + pred + "]"
+}
+
+// satisfies tells whether node satisfies pred.
+func (p *decodeState) satisfies(node gokoxml.Node, pred *predicate) bool {
+	if pred.attr != "" {
+		for name, a := range node.Attributes() {
+			if p.key(name) == pred.attr {
+				return a.Content() == pred.value
+			}
+		}
+		return false
+	}
+
+	// Count the siblings of the same name before node, or after it
+	// for negative indexes, as far as the index goes.
+	name := p.key(node.Name())
+	same := func(sib gokoxml.Node) bool {
+		return sib.NodeType() == gokoxml.XML_ELEMENT_NODE && p.key(sib.Name()) == name
+	}
+	if pred.index >= 0 {
+		i := 0
+		for sib := node.PreviousSibling(); sib != nil && i <= pred.index; sib = sib.PreviousSibling() {
+			if same(sib) {
+				i++
+			}
+		}
+		return i == pred.index
+	}
+	i := -1
+	for sib := node.NextSibling(); sib != nil && i >= pred.index; sib = sib.NextSibling() {
+		if same(sib) {
+			i--
+		}
+	}
+	return i == pred.index
+}
@@ -0,0 +1,111 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"reflect"
+	"strings"
+)
+
+const pathsTestString = `<Product>
+  <ListPrice><Amount>10</Amount><Currency>EUR</Currency></ListPrice>
+  <Items>
+    <Book><Value>a</Value></Book>
+    <Disc><Value>b</Value><Extra><Note>deep</Note></Extra></Disc>
+  </Items>
+  <Wrapper><Renamed><Info><Note>one</Note></Info></Renamed></Wrapper>
+  <Tags><Tag>x</Tag><Label>y</Label><Tag>z</Tag></Tags>
+</Product>`
+
+func (s *lXMLSuite) TestUnmarshalPathPatterns(c *C) {
+	var v struct {
+		Amount   float64  `xml:"Price|ListPrice>Amount"`
+		Currency string   `xml:"Price|ListPrice>Currency,required"`
+		Values   []string `xml:"Items>*>Value"`
+		Info     string   `xml:"Wrapper>>Info>Note"`
+		Tags     []string `xml:"Tags>Tag|Label"`
+	}
+	c.Assert(Unmarshal([]byte(pathsTestString), &v), IsNil)
+	c.Check(v.Amount, Equals, float64(10))
+	c.Check(v.Currency, Equals, "EUR")
+	c.Check(v.Values, DeepEquals, []string{"a", "b"})
+	c.Check(v.Info, Equals, "one")
+	c.Check(v.Tags, DeepEquals, []string{"x", "y", "z"})
+
+	// Items>*>Value would run through an Items>>Note element.
+	var n struct {
+		Notes  []string `xml:"Items>>Note"`
+		Values []string `xml:"Items>Book|Disc>Value"`
+	}
+	c.Assert(Unmarshal([]byte(pathsTestString), &n), IsNil)
+	c.Check(n.Notes, DeepEquals, []string{"deep"})
+	c.Check(n.Values, DeepEquals, []string{"a", "b"})
+
+	var r struct {
+		Deep string `xml:"Wrapper>>Missing,required"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &r), ErrorMatches, `xml: line 1: <Product> lacks required element "Wrapper>>Missing"`)
+	var r2 struct {
+		Deep string `xml:"Wrapper>>Info>Note,required"`
+		Any  string `xml:"Items>*>Value,required"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &r2), IsNil)
+}
+
+func (s *lXMLSuite) TestUnmarshalPathPatternsInvalid(c *C) {
+	var v1 struct {
+		A string `xml:"Price|ListPrice>Amount"`
+		B string `xml:"ListPrice>Amount"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &v1), ErrorMatches,
+		`struct .* field "A" with tag "Price\|ListPrice>Amount" conflicts with field "B" with tag "ListPrice>Amount"`)
+
+	var v2 struct {
+		A string `xml:"Items>*>Value"`
+		B string `xml:"Items>Book"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &v2), ErrorMatches, `.* conflicts with field "B" .*`)
+
+	var v3 struct {
+		A []string `xml:"Items>>Note"`
+		B string   `xml:"Items>Disc>Note"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &v3), ErrorMatches, `.* conflicts with field "B" .*`)
+
+	var v4 struct {
+		A []string `xml:"Items>>Note"`
+		B string   `xml:"Items>Book>Value"`
+		C string   `xml:"Wrapper>*>Other"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &v4), IsNil)
+
+	var v5 struct {
+		A string `xml:"Items>>Book"`
+		B string `xml:"Items>Book>Value"`
+	}
+	c.Check(Unmarshal([]byte(pathsTestString), &v5), ErrorMatches, `.* conflicts with field "B" .*`)
+
+	overlaps := []struct {
+		a, b string
+		ok   bool
+	}{
+		{"A>>X", "A>X>Z", true},
+		{"A>>X", "A>B>C>X", true},
+		{"A>>X", "A>B>Z", false},
+		{"A>>X", "B>>X", false},
+		{"A>>X>Y", "A>>Y", true},
+		{"A>>X>Y", "A>>Z", false},
+		{"A>*>X", "A>>X>Y", true},
+		{"A>B[0]", "A>B>C", false},
+		{"A>B[0]", "A>>B>C", false},
+		{"A>B", "A>>B>C", true},
+		{"A>*>X", "A>>B", true},
+	}
+	for _, o := range overlaps {
+		c.Check(pathsOverlap(strings.Split(o.a, ">"), strings.Split(o.b, ">")), Equals, o.ok, Commentf("%s %s", o.a, o.b))
+	}
+
+	for _, tag := range []string{"A>>>B", "A|>B", "*|A>B", "A>>"} {
+		_, err := structFieldInfo(nil, &reflect.StructField{Name: "F", Tag: reflect.StructTag(`xml:"` + tag + `"`), Type: reflect.TypeOf("")})
+		c.Check(err, NotNil, Commentf("tag %q", tag))
+	}
+}
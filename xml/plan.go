@@ -0,0 +1,258 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// decodePlan holds the lookup tables used to bind elements and
+// attributes to the fields of a struct type, so the cost of decoding
+// follows the size of the document rather than the number of fields.
+type decodePlan struct {
+	tinfo *typeInfo
+
+	// attrs maps attribute names to their fields.
+	attrs map[string]*fieldInfo
+
+	// paths is the root of the trie formed by the element paths.
+	paths *pathNode
+
+	// any is the field receiving the elements matched by no path.
+	any *fieldInfo
+
+	// pis holds the fields receiving processing instructions.
+	pis []*fieldInfo
+
+	// other holds the fields bound to the element as a whole.
+	other []*fieldInfo
+
+	// checks holds the fields with a default value or required.
+	checks []*fieldInfo
+
+	// after and validate tell whether pointers to the type implement
+	// AfterUnmarshaler and Validator.
+	after, validate bool
+}
+
+// pathNode is a node in the trie of element paths. Since no field path
+// may be a prefix of another one, a node ending the path of a field
+// only leads to deeper nodes on the paths of attributes below the
+// element of the field. Alternative names lead to
+// the same field through several children, the wildcard is the child
+// "*".
+type pathNode struct {
+	finfo    *fieldInfo
+	children map[string]*pathNode
+
+	// desc is the node of the paths continuing at any depth below
+	// the element matched by n.
+	desc *pathNode
+
+	// attrs maps the names of the attributes of the element matched
+	// by n to their fields.
+	attrs map[string]*fieldInfo
+
+	// preds holds the paths with a predicate on the step matched by n.
+	preds []predNode
+
+	// slice tells whether finfo is a slice of element values.
+	slice bool
+}
+
+// planKey identifies a decodePlan. Plans depend on the type and on how
+// the Decoder matches names.
+type planKey struct {
+	typ    reflect.Type
+	mapper *NameMapper
+	fold   bool
+}
+
+var planMap = make(map[planKey]*decodePlan)
+var planLock sync.RWMutex
+
+// getDecodePlan returns the decodePlan for the struct type typ. The
+// names of fields without a name in their tag are mapped by mapper,
+// if not nil, and all names are lower cased if fold is set.
+func getDecodePlan(typ reflect.Type, mapper *NameMapper, fold bool) (*decodePlan, error) {
+	key := planKey{typ, mapper, fold}
+	planLock.RLock()
+	plan, ok := planMap[key]
+	planLock.RUnlock()
+	if ok {
+		return plan, nil
+	}
+
+	tinfo, err := getTypeInfo(typ)
+	if err != nil {
+		return nil, err
+	}
+	if mapper != nil || fold {
+		tinfo = tinfo.rename(mapper, fold)
+	}
+
+	plan = &decodePlan{
+		tinfo: tinfo,
+		attrs: make(map[string]*fieldInfo),
+		paths: new(pathNode),
+
+		after:    reflect.PtrTo(typ).Implements(afterUnmarshalerType),
+		validate: reflect.PtrTo(typ).Implements(validatorType),
+	}
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		switch finfo.flags & fMode {
+		case fElement:
+			ft := typ.FieldByIndex(finfo.idx).Type
+			slice := ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8
+			plan.paths.add(finfo, slice)
+		case fAttr:
+			if len(finfo.parents) > 0 {
+				plan.paths.add(finfo, false)
+				break
+			}
+			for _, name := range strings.Split(finfo.name, "|") {
+				plan.attrs[name] = finfo
+			}
+		case fAny:
+			if plan.any == nil {
+				plan.any = finfo
+			}
+		case fPI:
+			plan.pis = append(plan.pis, finfo)
+		default:
+			plan.other = append(plan.other, finfo)
+		}
+		if finfo.flags&(fDefault|fRequired) != 0 {
+			plan.checks = append(plan.checks, finfo)
+		}
+	}
+
+	planLock.Lock()
+	planMap[key] = plan
+	planLock.Unlock()
+	return plan, nil
+}
+
+// rename returns a copy of tinfo with the names of the fields changed
+// as described for getDecodePlan.
+func (tinfo *typeInfo) rename(mapper *NameMapper, fold bool) *typeInfo {
+	name := func(finfo *fieldInfo) {
+		if mapper != nil && finfo.flags&fFieldName != 0 {
+			finfo.name = mapper.MapName(finfo.name)
+		}
+		if fold {
+			finfo.name = foldStep(finfo.name)
+			parents := make([]string, len(finfo.parents))
+			for i, parent := range finfo.parents {
+				parents[i] = foldStep(parent)
+			}
+			finfo.parents = parents
+		}
+	}
+
+	t := &typeInfo{fields: make([]fieldInfo, len(tinfo.fields))}
+	copy(t.fields, tinfo.fields)
+	for i := range t.fields {
+		name(&t.fields[i])
+	}
+	if tinfo.xmlname != nil {
+		xmlname := *tinfo.xmlname
+		name(&xmlname)
+		t.xmlname = &xmlname
+	}
+	return t
+}
+
+// add inserts the path of finfo below n.
+func (n *pathNode) add(finfo *fieldInfo, slice bool) {
+	if finfo.flags&fAttr != 0 {
+		n.insert(finfo.parents, finfo, false)
+		return
+	}
+	n.insert(finfo.path(), finfo, slice)
+}
+
+// insert inserts the steps of path leading to finfo below n. The path
+// of an attribute field ends with the element holding it.
+func (n *pathNode) insert(path []string, finfo *fieldInfo, slice bool) {
+	if len(path) == 0 {
+		if n.attrs == nil {
+			n.attrs = make(map[string]*fieldInfo)
+		}
+		for _, name := range strings.Split(finfo.name, "|") {
+			n.attrs[name] = finfo
+		}
+		return
+	}
+	if path[0] == "" {
+		if n.desc == nil {
+			n.desc = new(pathNode)
+		}
+		n.desc.insert(path[1:], finfo, slice)
+		return
+	}
+	names, pred := splitPredicate(path[0])
+	for _, name := range strings.Split(names, "|") {
+		c := n.child(name)
+		if pred != "" {
+			c = c.predicated(pred)
+		}
+		if len(path) == 1 && finfo.flags&fAttr == 0 {
+			c.finfo, c.slice = finfo, slice
+		} else {
+			c.insert(path[1:], finfo, slice)
+		}
+	}
+}
+
+// child returns the child of n called name, creating it if necessary.
+func (n *pathNode) child(name string) *pathNode {
+	if n.children == nil {
+		n.children = make(map[string]*pathNode)
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = new(pathNode)
+		n.children[name] = c
+	}
+	return c
+}
+
+// A predNode leads to the paths continuing from a step with predicate
+// pred.
+type predNode struct {
+	text string
+	pred *predicate
+	node *pathNode
+}
+
+// predicated returns the node of the paths with the predicate text on
+// the step of n, creating it if necessary.
+func (n *pathNode) predicated(text string) *pathNode {
+	for _, pn := range n.preds {
+		if pn.text == text {
+			return pn.node
+		}
+	}
+	pred, _ := parsePredicate(text)
+	pn := predNode{text, pred, new(pathNode)}
+	n.preds = append(n.preds, pn)
+	return pn.node
+}
+
+// matches tells whether a path continues from n with the element name.
+func (n *pathNode) matches(name string) bool {
+	if n.desc != nil {
+		return true
+	}
+	_, ok := n.children[name]
+	if !ok {
+		_, ok = n.children["*"]
+	}
+	return ok
+}
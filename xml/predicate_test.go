@@ -0,0 +1,65 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"reflect"
+)
+
+const predicateTestString = `<ItemLookupResponse>
+  <OperationRequest>
+    <Arguments>
+      <Argument Name="Service" Value="AWSECommerceService"/>
+      <Argument Name="Operation" Value="ItemLookup"/>
+      <Argument Name="ItemId" Value="B000"/>
+    </Arguments>
+  </OperationRequest>
+  <Offers>
+    <Offer><Merchant><Name>First</Name></Merchant><Price>10</Price></Offer>
+    <Note/>
+    <Offer><Merchant><Name>Second</Name></Merchant><Price>12</Price></Offer>
+    <Offer><Merchant><Name>Third</Name></Merchant><Price>9</Price></Offer>
+  </Offers>
+</ItemLookupResponse>`
+
+func (s *lXMLSuite) TestUnmarshalPredicates(c *C) {
+	var v struct {
+		First     string   `xml:"Offers>Offer[0]>Merchant>Name"`
+		Second    string   `xml:"Offers>Offer[1]>Merchant>Name"`
+		Last      string   `xml:"Offers>Offer[-1]>Merchant>Name"`
+		LastPrice float64  `xml:"Offers>Offer[-1]>Price"`
+		Prices    []string `xml:"Offers>Offer>Price"`
+		Missing   string   `xml:"Offers>Offer[3]>Merchant>Name"`
+		Operation string   `xml:"OperationRequest>Arguments>Argument[@Name=Operation]>@Value"`
+		ItemId    string   `xml:"OperationRequest>Arguments>Argument[@Name='ItemId']>Value,attr,required"`
+		LastArg   string   `xml:"OperationRequest>Arguments>Argument[-1]>@Name"`
+	}
+	c.Assert(Unmarshal([]byte(predicateTestString), &v), IsNil)
+	c.Check(v.First, Equals, "First")
+	c.Check(v.Second, Equals, "Second")
+	c.Check(v.Last, Equals, "Third")
+	c.Check(v.LastPrice, Equals, float64(9))
+	c.Check(v.Prices, DeepEquals, []string{"10", "12", "9"})
+	c.Check(v.Missing, Equals, "")
+	c.Check(v.Operation, Equals, "ItemLookup")
+	c.Check(v.ItemId, Equals, "B000")
+	c.Check(v.LastArg, Equals, "ItemId")
+
+	var r struct {
+		Locale string `xml:"OperationRequest>Arguments>Argument[@Name=Locale]>@Value,required"`
+	}
+	c.Check(Unmarshal([]byte(predicateTestString), &r), ErrorMatches,
+		`xml: line 1: <ItemLookupResponse> lacks required attribute "OperationRequest>Arguments>Argument\[@Name=Locale\]>Value"`)
+
+	var f struct {
+		Operation string `xml:"operationrequest>arguments>argument[@name=Operation]>@value"`
+	}
+	c.Assert((&Decoder{CaseInsensitive: true}).Decode([]byte(predicateTestString), &f), IsNil)
+	c.Check(f.Operation, Equals, "ItemLookup")
+}
+
+func (s *lXMLSuite) TestUnmarshalPredicatesInvalid(c *C) {
+	for _, tag := range []string{"A[x]>B", "A|B[0]>C", "*[0]>C", "A[@=x]>B", "A[0", "A>B[0],attr"} {
+		_, err := structFieldInfo(nil, &reflect.StructField{Name: "F", Tag: reflect.StructTag(`xml:"` + tag + `"`), Type: reflect.TypeOf("")})
+		c.Check(err, NotNil, Commentf("tag %q", tag))
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"encoding/xml"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+	"strings"
+)
+
+var procInstType = reflect.TypeOf(xml.ProcInst{})
+
+// addComment adds the comment src to dst. Strings and byte slices
+// accumulate the text of all comments, other slices receive one
+// element per comment.
+func (p *decodeState) addComment(dst reflect.Value, src string, finfo *fieldInfo) error {
+	if isOptional(dst.Type()) {
+		return p.addComment(dst.Addr().Interface().(optional).present(false), src, finfo)
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	switch {
+	case dst.Kind() == reflect.String:
+		dst.SetString(dst.String() + src)
+	case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+		dst.SetBytes(append(dst.Bytes(), src...))
+	case dst.Kind() == reflect.Slice:
+		n := dst.Len()
+		if err := p.Limits.checkSlice(n + 1); err != nil {
+			return err
+		}
+		dst.Set(reflect.Append(dst, reflect.Zero(dst.Type().Elem())))
+		if err := p.copyValue(dst.Index(n), src, finfo); err != nil {
+			dst.SetLen(n)
+			return err
+		}
+	default:
+		return p.copyValue(dst, src, finfo)
+	}
+	return nil
+}
+
+// unmarshalPI stores the processing instruction node in the fields of
+// sv tagged with its target, or with ",pi" alone.
+func (p *decodeState) unmarshalPI(pis []*fieldInfo, sv reflect.Value, node gokoxml.Node) error {
+	target := p.key(node.Name())
+	for _, finfo := range pis {
+		if finfo.name != "" && finfo.name != target {
+			continue
+		}
+		if err := p.copyPI(sv.FieldByIndex(finfo.idx), node, finfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPI stores the processing instruction node in dst. An
+// xml.ProcInst receives the target and the instruction, other structs
+// the pseudo-attributes of the instruction, like href="a.xsl", slices
+// one element per instruction and anything else the instruction text.
+func (p *decodeState) copyPI(dst reflect.Value, node gokoxml.Node, finfo *fieldInfo) error {
+	if isOptional(dst.Type()) {
+		return p.copyPI(dst.Addr().Interface().(optional).present(false), node, finfo)
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	conv, err := p.converter(dst.Type(), finfo)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case conv.IsValid():
+		if err := convert(conv, dst, node.Content()); err != nil {
+			return locate(err, node)
+		}
+	case dst.Type() == procInstType:
+		dst.Set(reflect.ValueOf(xml.ProcInst{Target: node.Name(), Inst: []byte(node.Content())}))
+	case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() != reflect.Uint8:
+		n := dst.Len()
+		if err := p.Limits.checkSlice(n + 1); err != nil {
+			return err
+		}
+		dst.Set(reflect.Append(dst, reflect.Zero(dst.Type().Elem())))
+		if err := p.copyPI(dst.Index(n), node, finfo); err != nil {
+			dst.SetLen(n)
+			return err
+		}
+	case dst.Kind() == reflect.Struct && dst.Type() != timeType && !isBigType(dst.Type()):
+		plan, err := getDecodePlan(dst.Type(), p.NameMapper, p.CaseInsensitive)
+		if err != nil {
+			return err
+		}
+		for _, attr := range pseudoAttrs(node.Content()) {
+			if finfo, ok := plan.attrs[p.key(attr[0])]; ok {
+				if err := p.copyValue(dst.FieldByIndex(finfo.idx), attr[1], finfo); err != nil {
+					return locate(err, node)
+				}
+			}
+		}
+	default:
+		if err := p.copyValue(dst, node.Content(), finfo); err != nil {
+			return locate(err, node)
+		}
+	}
+	return nil
+}
+
+// pseudoAttrs returns the name="value" pairs in the instruction s. It
+// stops at the first malformed pair.
+func pseudoAttrs(s string) (attrs [][2]string) {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		i := strings.Index(s, "=")
+		if i <= 0 {
+			return
+		}
+		name := strings.TrimRight(s[:i], " \t\r\n")
+		s = strings.TrimLeft(s[i+1:], " \t\r\n")
+		if s == "" || s[0] != '"' && s[0] != '\'' {
+			return
+		}
+		j := strings.IndexByte(s[1:], s[0])
+		if j < 0 {
+			return
+		}
+		attrs = append(attrs, [2]string{name, s[1 : j+1]})
+		s = s[j+2:]
+	}
+}
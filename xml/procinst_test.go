@@ -0,0 +1,112 @@
+package xml
+
+import (
+	"encoding/xml"
+	. "launchpad.net/gocheck"
+)
+
+const procInstTestString = `<?xml version="1.0"?>
+<?xml-stylesheet type="text/xsl" href="feed.xsl"?>
+<!-- generated -->
+<Feed>
+  <!-- first -->
+  <?app-cache ttl=60?>
+  <Item>a</Item>
+  <!-- second -->
+  <?app-cache ttl=30?>
+</Feed>`
+
+type procInstStylesheet struct {
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+func (s *lXMLSuite) TestUnmarshalComments(c *C) {
+	var v struct {
+		Comment string `xml:",comment"`
+	}
+	c.Assert(Unmarshal([]byte(procInstTestString), &v), IsNil)
+	c.Check(v.Comment, Equals, " first  second ")
+
+	var w struct {
+		Comment []string `xml:",comment"`
+	}
+	c.Assert(Unmarshal([]byte(procInstTestString), &w), IsNil)
+	c.Check(w.Comment, DeepEquals, []string{" first ", " second "})
+
+	var b struct {
+		Comment []byte `xml:",comment"`
+	}
+	c.Assert(Unmarshal([]byte(procInstTestString), &b), IsNil)
+	c.Check(string(b.Comment), Equals, " first  second ")
+}
+
+func (s *lXMLSuite) TestUnmarshalCommentsInvalid(c *C) {
+	var v struct {
+		Comment []int `xml:",comment"`
+	}
+	err := Unmarshal([]byte(`<R><!-- 1 --><!--x--><!-- 3 --></R>`), &v)
+	c.Check(err, ErrorMatches, `strconv.ParseInt: parsing "x": invalid syntax`)
+	c.Check(v.Comment, DeepEquals, []int{1})
+
+	d := &Decoder{Limits: Limits{SliceLength: 1}}
+	var w struct {
+		Comment []string `xml:",comment"`
+	}
+	c.Check(d.Decode([]byte(procInstTestString), &w), DeepEquals, &LimitError{"SliceLength", 1})
+	c.Check(w.Comment, DeepEquals, []string{" first "})
+
+	var x struct {
+		Cache []string `xml:"app-cache,pi"`
+	}
+	c.Check(d.Decode([]byte(procInstTestString), &x), DeepEquals, &LimitError{"SliceLength", 1})
+	c.Check(x.Cache, DeepEquals, []string{"ttl=60"})
+}
+
+func (s *lXMLSuite) TestUnmarshalProcInst(c *C) {
+	var v struct {
+		Stylesheet procInstStylesheet `xml:"xml-stylesheet,pi"`
+		Cache      []string           `xml:"app-cache,pi"`
+		All        []xml.ProcInst     `xml:",pi"`
+		Missing    *string            `xml:"missing,pi"`
+		Item       string
+	}
+	c.Assert(Unmarshal([]byte(procInstTestString), &v), IsNil)
+	c.Check(v.Stylesheet, Equals, procInstStylesheet{Type: "text/xsl", Href: "feed.xsl"})
+	c.Check(v.Cache, DeepEquals, []string{"ttl=60", "ttl=30"})
+	c.Assert(v.All, HasLen, 3)
+	c.Check(v.All[0].Target, Equals, "xml-stylesheet")
+	c.Check(string(v.All[2].Inst), Equals, "ttl=30")
+	c.Check(v.Missing, IsNil)
+	c.Check(v.Item, Equals, "a")
+
+	var w struct {
+		Raw string `xml:"xml-stylesheet,pi"`
+	}
+	c.Assert(Unmarshal([]byte(procInstTestString), &w), IsNil)
+	c.Check(w.Raw, Equals, `type="text/xsl" href="feed.xsl"`)
+}
+
+func (s *lXMLSuite) TestUnmarshalProcInstNested(c *C) {
+	var v struct {
+		Item struct {
+			Hint int `xml:"hint,pi"`
+		}
+		Outer string `xml:"hint,pi"`
+	}
+	c.Assert(Unmarshal([]byte(`<?hint 1?><R><Item><?hint 7?></Item></R>`), &v), IsNil)
+	c.Check(v.Item.Hint, Equals, 7)
+	c.Check(v.Outer, Equals, "1")
+}
+
+func (s *lXMLSuite) TestProcInstInvalidTag(c *C) {
+	var v struct {
+		A string `xml:"a>b,pi"`
+	}
+	c.Check(Unmarshal([]byte(`<R/>`), &v), ErrorMatches, "xml: invalid target .*")
+}
+
+func (s *lXMLSuite) TestPseudoAttrs(c *C) {
+	c.Check(pseudoAttrs(` a="1"  b = '2' c`), DeepEquals, [][2]string{{"a", "1"}, {"b", "2"}})
+	c.Check(pseudoAttrs(`ttl=60`), IsNil)
+}
@@ -0,0 +1,225 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+/*
+#cgo pkg-config: libxml-2.0
+#include <libxml/tree.h>
+#include <libxml/parserInternals.h>
+
+// collapse trims the whitespace around the value v, as the whitespace
+// facet of xs:QName says. It returns a new string and frees v.
+static xmlChar *collapse(xmlChar *v) {
+	const xmlChar *start = v;
+	int len;
+	xmlChar *ret;
+
+	while (IS_BLANK_CH(*start)) {
+		start++;
+	}
+	len = xmlStrlen(start);
+	while (len > 0 && IS_BLANK_CH(start[len - 1])) {
+		len--;
+	}
+	ret = xmlStrndup(start, len);
+	xmlFree(v);
+	return ret;
+}
+
+// xsiType returns the value of the xsi:type attribute of node with
+// the whitespace around it removed, or NULL. The namespace its prefix
+// is bound to in the scope of node is stored in *space, and the length
+// of the prefix in *prefixLen. *space is NULL if the prefix is not
+// declared.
+static xmlChar *xsiType(xmlNodePtr node, const xmlChar **space, int *prefixLen) {
+	xmlChar *v, *prefix = NULL;
+	xmlNsPtr ns;
+
+	*space = NULL;
+	*prefixLen = 0;
+	if (node == NULL || node->type != XML_ELEMENT_NODE || node->properties == NULL) {
+		return NULL;
+	}
+	v = xmlGetNsProp(node, BAD_CAST "type",
+		BAD_CAST "http://www.w3.org/2001/XMLSchema-instance");
+	if (v == NULL || (v = collapse(v)) == NULL) {
+		return NULL;
+	}
+	if (xmlSplitQName3(v, prefixLen) != NULL) {
+		prefix = xmlStrndup(v, *prefixLen);
+	}
+	ns = xmlSearchNs(node->doc, node, prefix);
+	if (ns != NULL) {
+		*space = ns->href;
+	}
+	if (prefix != NULL) {
+		xmlFree(prefix);
+	}
+	return v;
+}
+
+static void freeString(xmlChar *s) {
+	xmlFree(s);
+}
+*/
+import "C"
+
+import (
+	"encoding/xml"
+	"fmt"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// RegisterType registers the type of v for the XML Schema type local in
+// namespace space. Interface fields are decoded into a new value of the
+// type registered for the xsi:type attribute of their element. v may
+// be a value or a pointer, a pointer to the new value is stored if
+// the value itself does not satisfy the interface.
+func (d *Decoder) RegisterType(space, local string, v interface{}) {
+	typ := reflect.TypeOf(v)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if d.types == nil {
+		d.types = make(map[xml.Name]reflect.Type)
+	}
+	d.types[xml.Name{Space: space, Local: local}] = typ
+}
+
+// RegisterElement registers the type of v for elements called local.
+// If local is empty, the name in the XMLName field of the type is used.
+// Interface fields without xsi:type are decoded into a new value of the
+// first type registered for the name of their element that satisfies
+// the interface, so a slice of interfaces tagged ",any" receives the
+// registered elements in document order. Like other element names,
+// local is matched without namespace, and regardless of case if the
+// Decoder is CaseInsensitive. It is an error if local is empty and the
+// type has no element name.
+func (d *Decoder) RegisterElement(local string, v interface{}) error {
+	typ := reflect.TypeOf(v)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if local == "" {
+		xmlname := lookupXMLName(typ)
+		if xmlname == nil {
+			return fmt.Errorf("xml: no element name for type %s", typ)
+		}
+		local = xmlname.name
+	}
+	if d.elements == nil {
+		d.elements = make(map[string][]element)
+	}
+	// Names are kept lower cased, so they are found regardless of
+	// whether the Decoder is CaseInsensitive when it decodes.
+	key := strings.ToLower(local)
+	d.elements[key] = append(d.elements[key], element{local, typ})
+	return nil
+}
+
+// element is a type registered for the elements called local.
+type element struct {
+	local string
+	typ   reflect.Type
+}
+
+// implements tells whether typ or a pointer to it satisfies iface.
+func implements(typ, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface)
+}
+
+// elementType returns the first type registered for elements called
+// name that satisfies iface, ignoring case if the Decoder does.
+func (p *decodeState) elementType(name string, iface reflect.Type) (reflect.Type, bool) {
+	for _, e := range p.elements[strings.ToLower(name)] {
+		if (p.CaseInsensitive || e.local == name) && implements(e.typ, iface) {
+			return e.typ, true
+		}
+	}
+	return nil, false
+}
+
+// hasType tells whether start may be decoded into an interface value
+// of type iface, by its xsi:type or its name.
+func (p *decodeState) hasType(start gokoxml.Node, iface reflect.Type) bool {
+	if _, ok, err := xsiType(start); ok || err != nil {
+		return true
+	}
+	_, ok := p.elementType(start.Name(), iface)
+	return ok
+}
+
+// unmarshalInterface decodes start into the interface value v, using
+// the type registered for its xsi:type or else for its name. Without
+// either, a non-nil pointer held by v is decoded into.
+func (p *decodeState) unmarshalInterface(v reflect.Value, start gokoxml.Node, finfo *fieldInfo) error {
+	name, ok, err := xsiType(start)
+	if err != nil {
+		return err
+	}
+
+	var typ reflect.Type
+	if ok {
+		typ, ok = p.types[name]
+		if !ok {
+			return UnmarshalError("xml: unregistered xsi:type " + typeName(name) + " of <" + start.Name() + ">")
+		}
+		if !implements(typ, v.Type()) {
+			return UnmarshalError("xml: type " + typ.String() + " registered for xsi:type " + typeName(name) + " does not implement " + v.Type().String())
+		}
+	} else if typ, ok = p.elementType(start.Name(), v.Type()); !ok {
+		if e := v.Elem(); e.Kind() == reflect.Ptr && !e.IsNil() {
+			return p.unmarshal(e, start, finfo)
+		}
+		return UnmarshalError("xml: no type registered for <" + start.Name() + "> to decode into " + v.Type().String())
+	}
+
+	nv := reflect.New(typ)
+	if err := p.unmarshal(nv.Elem(), start, finfo); err != nil {
+		return err
+	}
+	if typ.Implements(v.Type()) {
+		nv = nv.Elem()
+	}
+	v.Set(nv)
+	return nil
+}
+
+// typeName formats name in the {namespace}local notation.
+func typeName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// xsiType returns the type name given by the xsi:type attribute of node,
+// if it has one.
+func xsiType(node gokoxml.Node) (xml.Name, bool, error) {
+	var space *C.xmlChar
+	var prefixLen C.int
+	v := C.xsiType(C.xmlNodePtr(node.NodePtr()), &space, &prefixLen)
+	if v == nil {
+		return xml.Name{}, false, nil
+	}
+	value := C.GoString((*C.char)(unsafe.Pointer(v)))
+	C.freeString(v)
+
+	local := value
+	if prefixLen > 0 {
+		local = value[prefixLen+1:]
+	}
+	name := xml.Name{Local: local}
+	if space != nil {
+		name.Space = C.GoString((*C.char)(unsafe.Pointer(space)))
+	} else if prefixLen > 0 {
+		return xml.Name{}, false, UnmarshalError("xml: undeclared namespace prefix in xsi:type " + strconv.Quote(value))
+	}
+	return name, true, nil
+}
@@ -0,0 +1,139 @@
+package xml
+
+import (
+	"encoding/xml"
+	. "launchpad.net/gocheck"
+)
+
+type registryShape interface {
+	Area() float64
+}
+
+type registryCircle struct {
+	Radius float64 `xml:"radius,attr"`
+}
+
+func (c registryCircle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type registryRect struct {
+	Width, Height float64
+}
+
+func (r *registryRect) Area() float64 { return r.Width * r.Height }
+
+type registryLine struct {
+	XMLName xml.Name `xml:"line"`
+	Length  float64  `xml:"length,attr"`
+}
+
+func (l registryLine) Area() float64 { return 0 }
+
+const registryTestString = `<Drawing xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+    xmlns:g="urn:geometry" xmlns="urn:default">
+  <Main xsi:type="g:Circle" radius="2"/>
+  <Shape xsi:type="g:Circle" radius="1"/>
+  <Shape xsi:type=" g:Rectangle "><Width>2</Width><Height>3</Height></Shape>
+  <Shape xsi:type="Circle" radius="4"/>
+</Drawing>`
+
+func registryTestDecoder() *Decoder {
+	d := new(Decoder)
+	d.RegisterType("urn:geometry", "Circle", registryCircle{})
+	d.RegisterType("urn:geometry", "Rectangle", (*registryRect)(nil))
+	d.RegisterType("urn:default", "Circle", &registryCircle{})
+	return d
+}
+
+func (s *lXMLSuite) TestDecodeXsiType(c *C) {
+	var v struct {
+		Main  registryShape
+		Shape []registryShape
+	}
+	c.Assert(registryTestDecoder().Decode([]byte(registryTestString), &v), IsNil)
+	c.Check(v.Main, Equals, registryCircle{2})
+	c.Assert(v.Shape, HasLen, 3)
+	c.Check(v.Shape[0], Equals, registryCircle{1})
+	c.Check(v.Shape[1], DeepEquals, &registryRect{2, 3})
+	c.Check(v.Shape[2].Area(), Equals, float64(48))
+}
+
+func (s *lXMLSuite) TestDecodeXsiTypeErrors(c *C) {
+	var v struct {
+		Main registryShape
+	}
+	c.Check(Unmarshal([]byte(registryTestString), &v), ErrorMatches,
+		`xml: unregistered xsi:type {urn:geometry}Circle of <Main>`)
+
+	d := new(Decoder)
+	d.RegisterType("urn:geometry", "Circle", parallelTestItem{})
+	c.Check(d.Decode([]byte(registryTestString), &v), ErrorMatches,
+		`xml: type xml.parallelTestItem registered for xsi:type {urn:geometry}Circle does not implement xml.registryShape`)
+
+	c.Check(registryTestDecoder().Decode([]byte(`<Drawing><Main/></Drawing>`), &v), ErrorMatches,
+		`xml: no type registered for <Main> to decode into xml.registryShape`)
+	c.Check(registryTestDecoder().Decode([]byte(`<Drawing xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><Main xsi:type="x:Circle"/></Drawing>`), &v), ErrorMatches,
+		`xml: undeclared namespace prefix in xsi:type "x:Circle"`)
+
+	// Without xsi:type, a pointer already held is decoded into.
+	rect := new(registryRect)
+	v.Main = rect
+	c.Check(Unmarshal([]byte(`<Drawing><Main><Width>5</Width></Main></Drawing>`), &v), IsNil)
+	c.Check(rect.Width, Equals, float64(5))
+}
+
+const registryMixedString = `<Drawing>
+  <title>Mixed</title>
+  <circle radius="1"/>
+  <rect><Width>1</Width><Height>2</Height></rect>
+  <group/>
+  <line length="3"/>
+  <circle radius="2"/>
+</Drawing>`
+
+func (s *lXMLSuite) TestDecodeMixedElements(c *C) {
+	d := new(Decoder)
+	c.Assert(d.RegisterElement("group", parallelTestItem{}), IsNil)
+	c.Assert(d.RegisterElement("circle", registryCircle{}), IsNil)
+	c.Assert(d.RegisterElement("rect", &registryRect{}), IsNil)
+	c.Assert(d.RegisterElement("", registryLine{}), IsNil)
+
+	var v struct {
+		Title  string          `xml:"title"`
+		Shapes []registryShape `xml:",any"`
+	}
+	c.Assert(d.Decode([]byte(registryMixedString), &v), IsNil)
+	c.Check(v.Title, Equals, "Mixed")
+	c.Check(v.Shapes, DeepEquals, []registryShape{
+		registryCircle{1},
+		&registryRect{1, 2},
+		registryLine{xml.Name{Local: "line"}, 3},
+		registryCircle{2},
+	})
+
+	var names struct {
+		Title string     `xml:"title"`
+		Other []xml.Name `xml:",any"`
+	}
+	c.Assert(Unmarshal([]byte(registryMixedString), &names), IsNil)
+	c.Check(names.Other, DeepEquals, []xml.Name{{Local: "circle"}, {Local: "rect"}, {Local: "group"}, {Local: "line"}, {Local: "circle"}})
+
+	c.Check(d.RegisterElement("", registryCircle{}), ErrorMatches, "xml: no element name for type xml.registryCircle")
+}
+
+func (s *lXMLSuite) TestDecodeElementsCaseInsensitive(c *C) {
+	data := []byte(`<Drawing><CIRCLE radius="1"/><Circle radius="2"/></Drawing>`)
+	var v struct {
+		Shapes []registryShape `xml:",any"`
+	}
+
+	d := &Decoder{CaseInsensitive: true}
+	c.Assert(d.RegisterElement("circle", registryCircle{}), IsNil)
+	c.Assert(d.Decode(data, &v), IsNil)
+	c.Check(v.Shapes, DeepEquals, []registryShape{registryCircle{1}, registryCircle{2}})
+
+	v.Shapes = nil
+	d = new(Decoder)
+	c.Assert(d.RegisterElement("Circle", registryCircle{}), IsNil)
+	c.Assert(d.Decode(data, &v), IsNil)
+	c.Check(v.Shapes, DeepEquals, []registryShape{registryCircle{2}})
+}
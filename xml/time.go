@@ -0,0 +1,110 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayouts are the layouts used for time.Time values when
+// neither the field nor the Decoder specify any.
+var DefaultTimeLayouts = []string{time.RFC3339}
+
+// xsdLayouts holds the layouts for the XML Schema date and time types.
+// The time zone is optional in all of them, fractional seconds are
+// accepted by time.Parse anyway.
+var xsdLayouts = map[string][]string{
+	"xs:dateTime":   {"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05"},
+	"xs:date":       {"2006-01-02Z07:00", "2006-01-02"},
+	"xs:time":       {"15:04:05Z07:00", "15:04:05"},
+	"xs:gYearMonth": {"2006-01Z07:00", "2006-01"},
+}
+
+// parseLayouts parses the value of a layout tag option, a list of
+// layouts separated by '|'. XML Schema type names like xs:date are
+// replaced by their layouts.
+func parseLayouts(value string) ([]string, error) {
+	if value == "" {
+		return nil, errors.New("empty layout")
+	}
+	var layouts []string
+	for _, layout := range strings.Split(value, "|") {
+		layouts = appendLayout(layouts, layout)
+	}
+	return layouts, nil
+}
+
+func appendLayout(layouts []string, layout string) []string {
+	if xsd, ok := xsdLayouts[layout]; ok {
+		return append(layouts, xsd...)
+	}
+	return append(layouts, layout)
+}
+
+// isTimeType tells whether fields of type typ hold time.Time values,
+// directly or through pointers, Optional or slices.
+func isTimeType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	typ = optionalElem(typ)
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	return typ == timeType
+}
+
+// parseTime parses src with the layouts of finfo, or those of the
+// Decoder if finfo has none. Times without a time zone are placed in
+// the Location of the Decoder, as time.ParseInLocation does.
+func (p *decodeState) parseTime(src string, finfo *fieldInfo) (time.Time, error) {
+	src = strings.TrimSpace(src)
+
+	var err error
+	try := func(layout string) (t time.Time, ok bool) {
+		var e error
+		if p.Location != nil {
+			t, e = time.ParseInLocation(layout, src, p.Location)
+		} else {
+			t, e = time.Parse(layout, src)
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+		return t, e == nil
+	}
+
+	if finfo != nil && finfo.layouts != nil {
+		for _, layout := range finfo.layouts {
+			if t, ok := try(layout); ok {
+				return t, nil
+			}
+		}
+		return time.Time{}, err
+	}
+
+	layouts := p.TimeLayouts
+	if layouts == nil {
+		layouts = DefaultTimeLayouts
+	}
+	for _, layout := range layouts {
+		if xsd, ok := xsdLayouts[layout]; ok {
+			for _, l := range xsd {
+				if t, ok := try(l); ok {
+					return t, nil
+				}
+			}
+		} else if t, ok := try(layout); ok {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,119 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+	"reflect"
+	"time"
+)
+
+const timeTestString = `<Times zone="2012-03-01+02:00">
+  <Release>2012-03-01</Release>
+  <German>01.03.2012</German>
+  <Either>2012-03-01</Either>
+  <Either>01.03.2012</Either>
+  <Local>2012-03-01T10:30:00.5</Local>
+  <Clock>13:20:00Z</Clock>
+  <Month>2012-03</Month>
+</Times>`
+
+type timeTestResult struct {
+	Zone    time.Time   `xml:"zone,attr,layout=xs:date"`
+	Release time.Time   `xml:",layout=xs:date"`
+	German  time.Time   `xml:",layout=02.01.2006"`
+	Either  []time.Time `xml:",layout=02.01.2006|xs:date"`
+	Local   time.Time   `xml:",layout=xs:dateTime"`
+	Clock   time.Time   `xml:",layout=xs:time"`
+	Month   *time.Time  `xml:",layout=xs:gYearMonth"`
+}
+
+func (s *lXMLSuite) TestTimeLayouts(c *C) {
+	var v timeTestResult
+	c.Assert(Unmarshal([]byte(timeTestString), &v), IsNil)
+
+	march := time.Date(2012, 3, 1, 0, 0, 0, 0, time.UTC)
+	c.Check(v.Zone.Equal(time.Date(2012, 3, 1, 0, 0, 0, 0, time.FixedZone("", 7200))), Equals, true)
+	c.Check(v.Release, Equals, march)
+	c.Check(v.German, Equals, march)
+	c.Check(v.Either, DeepEquals, []time.Time{march, march})
+	c.Check(v.Local, Equals, time.Date(2012, 3, 1, 10, 30, 0, 5e8, time.UTC))
+	c.Check(v.Clock, Equals, time.Date(0, 1, 1, 13, 20, 0, 0, time.UTC))
+	c.Check(*v.Month, Equals, time.Date(2012, 3, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func (s *lXMLSuite) TestTimeDecoderDefaults(c *C) {
+	berlin := time.FixedZone("CET", 3600)
+	d := &Decoder{
+		TimeLayouts: []string{"02.01.2006", "xs:date"},
+		Location:    berlin,
+	}
+
+	var v struct {
+		Release time.Time
+		German  time.Time
+		Local   time.Time `xml:",layout=xs:dateTime"`
+	}
+	c.Assert(d.Decode([]byte(timeTestString), &v), IsNil)
+	c.Check(v.Release, Equals, time.Date(2012, 3, 1, 0, 0, 0, 0, berlin))
+	c.Check(v.German, Equals, time.Date(2012, 3, 1, 0, 0, 0, 0, berlin))
+	c.Check(v.Local, Equals, time.Date(2012, 3, 1, 10, 30, 0, 5e8, berlin))
+}
+
+func (s *lXMLSuite) TestTimeLayoutErrors(c *C) {
+	var v1 struct {
+		Release time.Time
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v1), ErrorMatches, `parsing time "2012-03-01".*`)
+
+	var v2 struct {
+		Release time.Time `xml:",layout="`
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v2), ErrorMatches, `xml: invalid option "layout=" .*: empty layout`)
+
+	var v3 struct {
+		Release time.Time `xml:",bogus=1"`
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v3), ErrorMatches, `xml: invalid option "bogus=1" .*: unknown option`)
+
+	// go vet rejects spaces in xml tags, so build the type at run time.
+	v4 := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Release",
+		Type: timeType,
+		Tag:  `xml:",layout=2006-01-02 15:04:05"`,
+	}}))
+	c.Check(Unmarshal([]byte(timeTestString), v4.Interface()), ErrorMatches, `xml: space in option of field Release .*`)
+
+	var v5 struct {
+		Release time.Time `xml:",layout='2006-01-02"`
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v5), ErrorMatches, `xml: unterminated quote .*`)
+
+	var v6 struct {
+		Release time.Time `xml:",layout=02.01.2006,02"`
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v6), ErrorMatches, `xml: unknown flag "02" .*`)
+
+	var v7 struct {
+		Release string `xml:",layout=xs:date"`
+	}
+	c.Check(Unmarshal([]byte(timeTestString), &v7), ErrorMatches, `xml: layout on non-time field Release of type .*`)
+
+	var v8 struct {
+		Day time.Time `xml:"day,attr,layout=xs:date"`
+	}
+	c.Check(Unmarshal([]byte(`<T day="2012-13-45"/>`), &v8), ErrorMatches, `parsing time "2012-13-45".*`)
+	c.Check(v8.Day.IsZero(), Equals, true)
+}
+
+func (s *lXMLSuite) TestTimeQuotedLayouts(c *C) {
+	var v struct {
+		Stamp   time.Time `xmlopt:"layout=2006-01-02 15:04:05"`
+		Updated time.Time `xml:"updated,attr" xmlopt:"layout='Mon, 02 Jan 2006 15:04:05 MST'"`
+		Short   time.Time `xml:",layout='2006,01,02'"`
+	}
+	data := `<Times updated="Thu, 01 Mar 2012 10:30:00 UTC"><Stamp>2012-03-01 10:30:00</Stamp><Short>2012,03,01</Short></Times>`
+	c.Assert(Unmarshal([]byte(data), &v), IsNil)
+	stamp := time.Date(2012, 3, 1, 10, 30, 0, 0, time.UTC)
+	c.Check(v.Stamp, Equals, stamp)
+	c.Check(v.Updated.Equal(stamp), Equals, true)
+	c.Check(v.Short, Equals, time.Date(2012, 3, 1, 0, 0, 0, 0, time.UTC))
+}
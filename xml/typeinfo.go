@@ -9,7 +9,10 @@ package xml
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/moovweb/gokogiri/css"
+	"github.com/moovweb/gokogiri/xpath"
 	"reflect"
 	"strings"
 	"sync"
@@ -28,6 +31,11 @@ type fieldInfo struct {
 	xmlns   string
 	flags   fieldFlags
 	parents []string
+	xpath   *xpath.Expression
+	layouts []string
+	def     string
+	facets  []*facet
+	conv    string
 }
 
 type fieldFlags int
@@ -39,10 +47,24 @@ const (
 	fInnerXml
 	fComment
 	fAny
+	fSelect
+	fPI
 
 	fOmitEmpty
-
-	fMode = fElement | fAttr | fCharData | fInnerXml | fComment | fAny
+	fBase64
+	fHex
+	fDefault
+	fRequired
+	fPreserve
+	fTrim
+	fCollapse
+
+	// fFieldName marks fields named after their Go field.
+	fFieldName
+
+	fMode   = fElement | fAttr | fCharData | fInnerXml | fComment | fAny | fSelect | fPI
+	fBinary = fBase64 | fHex
+	fSpace  = fPreserve | fTrim | fCollapse
 )
 
 var tinfoMap = make(map[reflect.Type]*typeInfo)
@@ -63,7 +85,7 @@ func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
 	if typ.Kind() == reflect.Struct && typ != nameType {
 		n := typ.NumField()
 		for i := 0; i < n; i++ {
-			f := typ.Field(i)
+			f := mappedField(typ, i)
 			if f.PkgPath != "" || f.Tag.Get("xml") == "-" {
 				continue // Private field
 			}
@@ -118,17 +140,55 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 
 	// Split the tag from the xml namespace if necessary.
 	tag := f.Tag.Get("xml")
-	if i := strings.Index(tag, " "); i >= 0 {
+
+	// Fields with a selector are bound to the nodes it matches below
+	// the element and take no part in path matching.
+	if sel, ok := selectorTag(f); ok {
+		if tag != "" || f.Name == "XMLName" {
+			return nil, fmt.Errorf("xml: selector combined with xml tag in field %s of type %s", f.Name, typ)
+		}
+		finfo.flags = fSelect
+		finfo.name = f.Name
+		if finfo.xpath = xpath.Compile(sel); finfo.xpath == nil {
+			return nil, fmt.Errorf("xml: invalid selector in field %s of type %s: %q", f.Name, typ, sel)
+		}
+		return finfo, nil
+	}
+	if i := indexUnquoted(tag, ' '); i >= 0 {
 		finfo.xmlns, tag = tag[:i], tag[i+1:]
+		if strings.ContainsAny(finfo.xmlns, ",=") {
+			return nil, fmt.Errorf("xml: space in option of field %s of type %s, quote it or use the xmlopt tag: %q",
+				f.Name, typ, f.Tag.Get("xml"))
+		}
 	}
 
-	// Parse flags.
-	tokens := strings.Split(tag, ",")
+	// Parse flags. The xmlopt tag holds more of them, and may hold
+	// values with spaces, which go vet rejects in xml tags.
+	tokens, err := splitTag(tag)
+	if err != nil {
+		return nil, fmt.Errorf("xml: %v in field %s of type %s: %q", err, f.Name, typ, f.Tag.Get("xml"))
+	}
+	if opt, ok := f.Tag.Lookup("xmlopt"); ok {
+		opts, err := splitTag(opt)
+		if err != nil {
+			return nil, fmt.Errorf("xml: %v in field %s of type %s: %q", err, f.Name, typ, opt)
+		}
+		tokens = append(tokens, opts...)
+	}
 	if len(tokens) == 1 {
 		finfo.flags = fElement
 	} else {
 		tag = tokens[0]
 		for _, flag := range tokens[1:] {
+			// Options with a value have the form name=value, the
+			// value may be quoted as in name='a, b'.
+			if i := strings.Index(flag, "="); i >= 0 {
+				if err := finfo.setOption(flag[:i], unquoteOption(flag[i+1:])); err != nil {
+					return nil, fmt.Errorf("xml: invalid option %q in field %s of type %s: %v",
+						flag, f.Name, typ, err)
+				}
+				continue
+			}
 			switch flag {
 			case "attr":
 				finfo.flags |= fAttr
@@ -140,8 +200,26 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 				finfo.flags |= fComment
 			case "any":
 				finfo.flags |= fAny
+			case "pi":
+				finfo.flags |= fPI
 			case "omitempty":
 				finfo.flags |= fOmitEmpty
+			case "base64":
+				finfo.flags |= fBase64
+			case "hex":
+				finfo.flags |= fHex
+			case "required":
+				finfo.flags |= fRequired
+			case "preserve":
+				finfo.flags |= fPreserve
+			case "trim":
+				finfo.flags |= fTrim
+			case "collapse":
+				finfo.flags |= fCollapse
+			default:
+				// Most likely the rest of an option value split at an
+				// unquoted comma.
+				return nil, fmt.Errorf("xml: unknown flag %q in field %s of type %s", flag, f.Name, typ)
 			}
 		}
 
@@ -150,8 +228,8 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 		switch mode := finfo.flags & fMode; mode {
 		case 0:
 			finfo.flags |= fElement
-		case fAttr, fCharData, fInnerXml, fComment, fAny:
-			if f.Name == "XMLName" || tag != "" && mode != fAttr {
+		case fAttr, fCharData, fInnerXml, fComment, fAny, fPI:
+			if f.Name == "XMLName" || tag != "" && mode != fAttr && mode != fPI {
 				valid = false
 			}
 		default:
@@ -161,10 +239,38 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 		if finfo.flags&fOmitEmpty != 0 && finfo.flags&(fElement|fAttr) == 0 {
 			valid = false
 		}
+		if finfo.flags&fBinary != 0 && (finfo.flags&fBinary == fBinary || !isBinaryType(f.Type)) {
+			valid = false
+		}
+		if finfo.flags&(fDefault|fRequired) != 0 && (finfo.flags&(fDefault|fRequired) == fDefault|fRequired ||
+			finfo.flags&(fElement|fAttr) == 0 || f.Name == "XMLName") {
+			valid = false
+		}
+		if finfo.facets != nil && finfo.flags&(fElement|fAttr|fCharData) == 0 {
+			valid = false
+		}
+		if sp := finfo.flags & fSpace; sp != 0 && (sp&(sp-1) != 0 || finfo.flags&(fElement|fAttr|fCharData) == 0) {
+			valid = false
+		}
 		if !valid {
 			return nil, fmt.Errorf("xml: invalid tag in field %s of type %s: %q",
 				f.Name, typ, f.Tag.Get("xml"))
 		}
+		if finfo.facets != nil {
+			if err := checkFacetType(f.Type, finfo); err != nil {
+				return nil, fmt.Errorf("xml: invalid facet in field %s of type %s: %v",
+					f.Name, typ, err)
+			}
+		}
+		if finfo.layouts != nil && !isTimeType(f.Type) {
+			return nil, fmt.Errorf("xml: layout on non-time field %s of type %s", f.Name, typ)
+		}
+		if finfo.flags&fDefault != 0 {
+			if err := checkDefault(f.Type, finfo); err != nil {
+				return nil, fmt.Errorf("xml: invalid default in field %s of type %s: %v",
+					f.Name, typ, err)
+			}
+		}
 	}
 
 	// Use of xmlns without a name is not allowed.
@@ -181,6 +287,16 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 		return finfo, nil
 	}
 
+	if finfo.flags&fPI != 0 {
+		// The name of a processing instruction field is its target,
+		// none stands for all targets.
+		if strings.ContainsAny(tag, ">|*@[") {
+			return nil, fmt.Errorf("xml: invalid target in field %s of type %s: %q", f.Name, typ, tag)
+		}
+		finfo.name = tag
+		return finfo, nil
+	}
+
 	if tag == "" {
 		// If the name part of the tag is completely empty, get
 		// default from XMLName of underlying struct if feasible,
@@ -189,6 +305,7 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 			finfo.xmlns, finfo.name = xmlname.xmlns, xmlname.name
 		} else {
 			finfo.name = f.Name
+			finfo.flags |= fFieldName
 		}
 		return finfo, nil
 	}
@@ -201,6 +318,20 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 	if tokens[len(tokens)-1] == "" {
 		return nil, fmt.Errorf("xml: trailing '>' in field %s of type %s", f.Name, typ)
 	}
+	if last := tokens[len(tokens)-1]; strings.HasPrefix(last, "@") {
+		// A path ending in @name is that of an attribute.
+		if finfo.flags&fMode == fElement {
+			finfo.flags = finfo.flags&^fElement | fAttr
+		}
+		if finfo.flags&fMode != fAttr {
+			return nil, fmt.Errorf("xml: invalid tag in field %s of type %s: %q",
+				f.Name, typ, f.Tag.Get("xml"))
+		}
+		tokens[len(tokens)-1] = last[1:]
+	}
+	if !validSteps(tokens) || finfo.flags&fAttr != 0 && !validAttrSteps(tokens) {
+		return nil, fmt.Errorf("xml: invalid path in field %s of type %s: %q", f.Name, typ, tag)
+	}
 	finfo.name = tokens[len(tokens)-1]
 	if len(tokens) > 1 {
 		finfo.parents = tokens[:len(tokens)-1]
@@ -212,7 +343,7 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 	if finfo.flags&fElement != 0 {
 		ftyp := f.Type
 		xmlname := lookupXMLName(ftyp)
-		if xmlname != nil && xmlname.name != finfo.name {
+		if xmlname != nil && !stepMatches(finfo.name, xmlname.name) {
 			return nil, fmt.Errorf("xml: name %q in tag of %s.%s conflicts with name %q in %s.XMLName",
 				finfo.name, typ, f.Name, xmlname.name, ftyp)
 		}
@@ -220,6 +351,87 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 	return finfo, nil
 }
 
+// setOption applies the tag option name=value to finfo.
+func (finfo *fieldInfo) setOption(name, value string) error {
+	switch name {
+	case "layout":
+		layouts, err := parseLayouts(value)
+		if err != nil {
+			return err
+		}
+		finfo.layouts = layouts
+	case "conv":
+		finfo.conv = value
+	case "default":
+		finfo.def = value
+		finfo.flags |= fDefault
+	case "enum", "pattern", "min", "max", "minLength", "maxLength":
+		f, err := newFacet(name, value)
+		if err != nil {
+			return err
+		}
+		finfo.facets = append(finfo.facets, f)
+	default:
+		return errors.New("unknown option")
+	}
+	return nil
+}
+
+// indexUnquoted returns the index of the first c in tag outside of
+// single quotes, or -1.
+func indexUnquoted(tag string, c byte) int {
+	quoted := false
+	for i := 0; i < len(tag); i++ {
+		switch tag[i] {
+		case '\'':
+			quoted = !quoted
+		case c:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTag splits tag at the commas outside of single quotes.
+func splitTag(tag string) ([]string, error) {
+	var tokens []string
+	for {
+		i := indexUnquoted(tag, ',')
+		if i < 0 {
+			break
+		}
+		tokens = append(tokens, tag[:i])
+		tag = tag[i+1:]
+	}
+	if strings.Count(tag, "'")%2 != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	return append(tokens, tag), nil
+}
+
+// unquoteOption returns the option value v without its single quotes.
+// Two single quotes inside a quoted value stand for one.
+func unquoteOption(v string) string {
+	if len(v) < 2 || v[0] != '\'' || v[len(v)-1] != '\'' {
+		return v
+	}
+	return strings.Replace(v[1:len(v)-1], "''", "'", -1)
+}
+
+// selectorTag returns the XPath expression given by the xpath or css
+// tag of f, if any.
+func selectorTag(f *reflect.StructField) (string, bool) {
+	if sel := f.Tag.Get("xpath"); sel != "" {
+		return sel, true
+	}
+	if sel := f.Tag.Get("css"); sel != "" {
+		return css.Convert(sel, css.LOCAL), true
+	}
+	return "", false
+}
+
 // lookupXMLName returns the fieldInfo for typ's XMLName field
 // in case it exists and has a valid xml field tag, otherwise
 // it returns nil.
@@ -231,7 +443,7 @@ func lookupXMLName(typ reflect.Type) (xmlname *fieldInfo) {
 		return nil
 	}
 	for i, n := 0, typ.NumField(); i < n; i++ {
-		f := typ.Field(i)
+		f := mappedField(typ, i)
 		if f.Name != "XMLName" {
 			continue
 		}
@@ -246,13 +458,6 @@ func lookupXMLName(typ reflect.Type) (xmlname *fieldInfo) {
 	return nil
 }
 
-func min(a, b int) int {
-	if a <= b {
-		return a
-	}
-	return b
-}
-
 // addFieldInfo adds finfo to tinfo.fields if there are no
 // conflicts, or if conflicts arise from previous fields that were
 // obtained from deeper embedded structures than finfo. In the latter
@@ -262,31 +467,21 @@ func min(a, b int) int {
 // It is okay for field paths to share a common, shorter prefix.
 func addFieldInfo(typ reflect.Type, tinfo *typeInfo, newf *fieldInfo) error {
 	var conflicts []int
-Loop:
 	// First, figure all conflicts. Most working code will have none.
 	for i := range tinfo.fields {
 		oldf := &tinfo.fields[i]
-		if oldf.flags&fMode != newf.flags&fMode {
+		if oldf.flags&fMode != newf.flags&fMode || newf.flags&(fSelect|fPI) != 0 {
 			continue
 		}
-		minl := min(len(newf.parents), len(oldf.parents))
-		for p := 0; p < minl; p++ {
-			if oldf.parents[p] != newf.parents[p] {
-				continue Loop
-			}
-		}
-		if len(oldf.parents) > len(newf.parents) {
-			if oldf.parents[len(newf.parents)] == newf.name {
-				conflicts = append(conflicts, i)
-			}
-		} else if len(oldf.parents) < len(newf.parents) {
-			if newf.parents[len(oldf.parents)] == oldf.name {
-				conflicts = append(conflicts, i)
-			}
-		} else {
-			if newf.name == oldf.name {
+		// Attributes only conflict with those of the same elements.
+		if newf.flags&fAttr != 0 {
+			if matchSteps(oldf.path(), newf.path(), false) {
 				conflicts = append(conflicts, i)
 			}
+			continue
+		}
+		if pathsOverlap(oldf.path(), newf.path()) {
+			conflicts = append(conflicts, i)
 		}
 	}
 	// Without conflicts, add the new field and return.
@@ -307,8 +502,8 @@ Loop:
 	for _, i := range conflicts {
 		oldf := &tinfo.fields[i]
 		if len(oldf.idx) == len(newf.idx) {
-			f1 := typ.FieldByIndex(oldf.idx)
-			f2 := typ.FieldByIndex(newf.idx)
+			f1 := mappedFieldByIndex(typ, oldf.idx)
+			f2 := mappedFieldByIndex(typ, newf.idx)
 			return &TagPathError{typ, f1.Name, f1.Tag.Get("xml"), f2.Name, f2.Tag.Get("xml")}
 		}
 	}
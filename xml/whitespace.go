@@ -0,0 +1,73 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+/*
+#cgo pkg-config: libxml-2.0
+#include <libxml/tree.h>
+*/
+import "C"
+
+import (
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"strings"
+)
+
+// Whitespace tells how the whitespace of text values is normalized
+// before they are stored.
+type Whitespace int
+
+const (
+	// WhitespacePreserve keeps text as it is.
+	WhitespacePreserve Whitespace = iota
+
+	// WhitespaceTrim removes leading and trailing whitespace.
+	WhitespaceTrim
+
+	// WhitespaceCollapse trims text and replaces runs of whitespace
+	// inside it by a single space, as the whitespace facet collapse
+	// of XML Schema.
+	WhitespaceCollapse
+)
+
+// whitespace returns the normalization of the value of finfo.
+func (p *decodeState) whitespace(finfo *fieldInfo) Whitespace {
+	if finfo != nil {
+		switch finfo.flags & fSpace {
+		case fPreserve:
+			return WhitespacePreserve
+		case fTrim:
+			return WhitespaceTrim
+		case fCollapse:
+			return WhitespaceCollapse
+		}
+	}
+	return p.Whitespace
+}
+
+// text returns src, the text of node or of one of its attributes,
+// normalized as finfo or the Decoder tell, unless xml:space="preserve"
+// applies to node.
+func (p *decodeState) text(src string, node gokoxml.Node, finfo *fieldInfo) string {
+	ws := p.whitespace(finfo)
+	if ws == WhitespacePreserve || spacePreserved(node) {
+		return src
+	}
+	if ws == WhitespaceCollapse {
+		return strings.Join(strings.FieldsFunc(src, isSpace), " ")
+	}
+	return strings.TrimFunc(src, isSpace)
+}
+
+// isSpace tells whether r is whitespace in XML.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+// spacePreserved tells whether xml:space="preserve" applies to node,
+// set on node or inherited from the closest element setting it.
+func spacePreserved(node gokoxml.Node) bool {
+	return C.xmlNodeGetSpacePreserve(C.xmlNodePtr(node.NodePtr())) == 1
+}
@@ -0,0 +1,70 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const whitespaceTestString = `<entry id="  e1 ">
+  <title>
+    An   Atom
+    title
+  </title>
+  <summary>  keep  </summary>
+  <content xml:space="preserve">  code  <b> x </b></content>
+  <author xml:space="preserve"><name>  Ann </name></author>
+  <count> 3 </count>
+</entry>`
+
+type whitespaceEntry struct {
+	ID      string `xml:"id,attr"`
+	Title   string `xml:"title,collapse"`
+	Summary string `xml:"summary,preserve"`
+	Content string `xml:"content,trim"`
+	Name    string `xml:"author>name,trim"`
+	Count   int    `xml:"count"`
+}
+
+func (s *lXMLSuite) TestUnmarshalWhitespaceOptions(c *C) {
+	var v whitespaceEntry
+	c.Assert(Unmarshal([]byte(whitespaceTestString), &v), IsNil)
+	c.Check(v.ID, Equals, "  e1 ")
+	c.Check(v.Title, Equals, "An Atom title")
+	c.Check(v.Summary, Equals, "  keep  ")
+	c.Check(v.Content, Equals, "  code   x ")
+	c.Check(v.Name, Equals, "  Ann ")
+	c.Check(v.Count, Equals, 3)
+}
+
+func (s *lXMLSuite) TestDecoderWhitespace(c *C) {
+	var v struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title"`
+		Text  struct {
+			Value string `xml:",chardata"`
+		} `xml:"summary"`
+	}
+	d := &Decoder{Whitespace: WhitespaceTrim}
+	c.Assert(d.Decode([]byte(whitespaceTestString), &v), IsNil)
+	c.Check(v.ID, Equals, "e1")
+	c.Check(v.Title, Equals, "An   Atom\n    title")
+	c.Check(v.Text.Value, Equals, "keep")
+
+	var e whitespaceEntry
+	d = &Decoder{Whitespace: WhitespaceCollapse}
+	c.Assert(d.Decode([]byte(whitespaceTestString), &e), IsNil)
+	c.Check(e.ID, Equals, "e1")
+	c.Check(e.Summary, Equals, "  keep  ")
+	c.Check(e.Content, Equals, "  code   x ")
+}
+
+func (s *lXMLSuite) TestWhitespaceInvalidTag(c *C) {
+	var v struct {
+		A string `xml:"a,trim,collapse"`
+	}
+	c.Check(Unmarshal([]byte(`<R/>`), &v), ErrorMatches, "xml: invalid tag .*")
+
+	var w struct {
+		A string `xml:",comment,trim"`
+	}
+	c.Check(Unmarshal([]byte(`<R/>`), &w), ErrorMatches, "xml: invalid tag .*")
+}
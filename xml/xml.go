@@ -5,12 +5,15 @@
 package xml
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	gokoxml "github.com/moovweb/gokogiri/xml"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,62 +28,246 @@ func Unmarshal(data []byte, v interface{}) error {
 	return new(Decoder).Decode(data, v)
 }
 
+// UnmarshalContext is like Unmarshal but stops early once ctx is done.
+func UnmarshalContext(ctx context.Context, data []byte, v interface{}) error {
+	return new(Decoder).DecodeContext(ctx, data, v)
+}
+
+// A Decoder binds XML documents to Go values. Once configured, a
+// Decoder may be used by multiple goroutines simultaneously.
 type Decoder struct {
-	doc *gokoxml.XmlDocument
+	// Parallel is the number of goroutines used to decode the
+	// elements of slice fields. With Parallel greater than one,
+	// slice fields that receive at least ParallelMin elements from
+	// one element are decoded in parallel, nested slices sharing the
+	// goroutines. The order of the elements in the slice is the
+	// document order either way.
+	//
+	// This relies on libxml2 allowing any number of threads to read
+	// a document tree as long as none of them modifies it, which
+	// Decode never does once the transforms have run. XPath contexts
+	// are not safe for concurrent use, so selector fields are
+	// evaluated one at a time.
+	Parallel int
+
+	// ParallelMin is the number of elements at which a slice field is
+	// decoded in parallel. If zero, DefaultParallelMin is used.
+	ParallelMin int
+
+	// Limits bounds the resources used by a single Decode. Documents
+	// exceeding them are rejected with a *LimitError.
+	Limits Limits
+
+	// TimeLayouts are the layouts tried in order for time.Time values
+	// whose field has no layout option. Besides time.Parse layouts,
+	// the XML Schema types xs:dateTime, xs:date, xs:time and
+	// xs:gYearMonth may be given. If nil, DefaultTimeLayouts is used.
+	TimeLayouts []string
+
+	// Location is the location of times without a time zone. If nil,
+	// UTC is used.
+	Location *time.Location
+
+	// NameMapper, if not nil, maps the names of fields without a name
+	// in their tag, which are otherwise the Go field names. Decoders
+	// should share NameMappers, see NewNameMapper.
+	NameMapper *NameMapper
+
+	// CaseInsensitive makes the names of elements and attributes match
+	// the names of fields regardless of case.
+	CaseInsensitive bool
+
+	// Whitespace is the normalization of text values for fields
+	// without a preserve, trim or collapse option. Text to which
+	// xml:space="preserve" applies is never normalized.
+	Whitespace Whitespace
+
+	transforms []transform
+	types      map[xml.Name]reflect.Type
+	elements   map[string][]element
+
+	converters      map[reflect.Type]reflect.Value
+	namedConverters map[string]reflect.Value
+}
+
+// DefaultParallelMin is the default for Decoder.ParallelMin.
+const DefaultParallelMin = 256
+
+// contextInterval is the number of values decoded between two checks
+// of the context of a decode.
+const contextInterval = 64
+
+// decodeState holds the state of a single call to Decode. It is shared
+// by the goroutines of a parallel decode.
+type decodeState struct {
+	// steps counts the values decoded, to check ctx every
+	// contextInterval of them. Accessed atomically.
+	steps int64
+
+	*Decoder
+	doc  *gokoxml.XmlDocument
+	ctx  context.Context
+	done <-chan struct{}
+
+	// workers holds a token for every goroutine started by a parallel
+	// decode besides the one of Decode, it is shared by nested slices.
+	workers chan struct{}
+
+	// searchLock serializes the use of the XPath context of doc.
+	searchLock sync.Mutex
 }
 
+var statePool = sync.Pool{New: func() interface{} { return new(decodeState) }}
+
 // TODO: Make the Parser options configureable.
 func (d *Decoder) Decode(data []byte, v interface{}) error {
+	return d.DecodeContext(context.Background(), data, v)
+}
+
+// DecodeContext is like Decode but stops early once ctx is done,
+// returning ctx.Err(). The context is checked regularly while data is
+// parsed and while the tree is bound to v, and after every transform.
+func (d *Decoder) DecodeContext(ctx context.Context, data []byte, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.Limits.checkInput(data); err != nil {
+		return err
+	}
+
 	var opts = gokoxml.DefaultParseOption | gokoxml.XML_PARSE_NOENT
-	doc, err := gokoxml.Parse(data, gokoxml.DefaultEncodingBytes, nil, opts, gokoxml.DefaultEncodingBytes)
-	d.doc = doc
+	doc, err := parseXML(ctx, data, opts, &d.Limits)
 	if err != nil {
 		return err
 	}
-	defer doc.Free()
+	return d.decode(ctx, doc, v)
+}
+
+// decode runs the attached transforms on doc and binds the result to v.
+// doc is freed when decode returns.
+func (d *Decoder) decode(ctx context.Context, doc *gokoxml.XmlDocument, v interface{}) error {
+	p := statePool.Get().(*decodeState)
+	p.Decoder, p.doc = d, doc
+	p.ctx, p.done, p.steps = ctx, ctx.Done(), 0
+	if d.Parallel > 1 {
+		p.workers = make(chan struct{}, d.Parallel-1)
+	}
+	defer func() {
+		p.doc.Free()
+		p.Decoder, p.doc, p.ctx, p.done, p.workers = nil, nil, nil, nil, nil
+		statePool.Put(p)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i := range d.transforms {
+		res, err := d.transforms[i].apply(p.doc)
+		if err != nil {
+			return err
+		}
+		p.doc.Free()
+		p.doc = res
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p.doc.Root() != nil && d.Limits.treeLimits() {
+			if err := checkTree(p.doc, &d.Limits); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.doc.Root() == nil {
+		return UnmarshalError("document has no root element")
+	}
 
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr {
 		return errors.New("non-pointer passed to Decode")
 	}
 
-	return d.unmarshal(val.Elem(), nil)
+	return p.unmarshal(val.Elem(), nil, nil)
 }
 
-func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
+// checkContext returns the error of the context of the decode if it is
+// done. To keep it cheap, the context is only looked at every
+// contextInterval calls.
+func (p *decodeState) checkContext() error {
+	if p.done == nil || atomic.AddInt64(&p.steps, 1)%contextInterval != 0 {
+		return nil
+	}
+	select {
+	case <-p.done:
+		return p.ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// unmarshal decodes start into val. finfo is the field val belongs to,
+// or nil for the value passed to Decode.
+func (p *decodeState) unmarshal(val reflect.Value, start gokoxml.Node, finfo *fieldInfo) error {
+	if err := p.checkContext(); err != nil {
+		return err
+	}
+
 	// Find first xml node.
-	if start == nil {
+	root := start == nil
+	if root {
 		start = p.doc.Root().XmlNode
 	}
 
-	// Unpacks a pointer
-	if pv := val; pv.Kind() == reflect.Ptr {
+	// An element marked with xsi:nil leaves a nil pointer or the zero
+	// value, slices of elements receive a zero element.
+	if t := optionalElem(val.Type()); finfo != nil && !(t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) && isNil(start) {
+		if isOptional(val.Type()) {
+			val.Addr().Interface().(optional).present(true)
+		} else {
+			val.Set(reflect.Zero(val.Type()))
+		}
+		return nil
+	}
+
+	// Unpacks a pointer, unless there is a converter for it.
+	conv, err := p.converter(val.Type(), finfo)
+	if err != nil {
+		return err
+	}
+	if pv := val; pv.Kind() == reflect.Ptr && !conv.IsValid() {
 		if pv.IsNil() {
 			pv.Set(reflect.New(pv.Type().Elem()))
 		}
 		val = pv.Elem()
+		if conv, err = p.converter(val.Type(), finfo); err != nil {
+			return err
+		}
+	}
+	if conv.IsValid() {
+		if err := p.copyValue(val, p.text(start.Content(), start, finfo), finfo); err != nil {
+			return locate(err, start)
+		}
+		return nil
 	}
 
 	var (
-		sv    reflect.Value
-		tinfo *typeInfo
-		err   error
+		sv   reflect.Value
+		plan *decodePlan
 	)
 
 	switch v := val; v.Kind() {
 	default:
 		return errors.New("unknown type " + v.Type().String())
 
-		// TODO: Implement this once i understand Skip()
-		// case reflect.Interface:
-		// 	return p.Skip()
+	case reflect.Interface:
+		return p.unmarshalInterface(v, start, finfo)
 
 	case reflect.Slice:
 		typ := v.Type()
 		if typ.Elem().Kind() == reflect.Uint8 {
 			// []byte
-			if err := copyValue(v, start.Content()); err != nil {
-				return err
+			if err := p.copyValue(v, p.text(start.Content(), start, finfo), finfo); err != nil {
+				return locate(err, start)
 			}
 			break
 		}
@@ -88,6 +275,9 @@ func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
 		// Slice of element values.
 		// Grow slice.
 		n := v.Len()
+		if err := p.Limits.checkSlice(n + 1); err != nil {
+			return err
+		}
 		if n >= v.Cap() {
 			ncap := 2 * n
 			if ncap < 4 {
@@ -100,15 +290,15 @@ func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
 		v.SetLen(n + 1)
 
 		// Recur to read element into slice.
-		if err := p.unmarshal(v.Index(n), start); err != nil {
+		if err := p.unmarshal(v.Index(n), start, finfo); err != nil {
 			v.SetLen(n)
 			return err
 		}
 		return nil
 
 	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.String:
-		if err := copyValue(v, start.Content()); err != nil {
-			return err
+		if err := p.copyValue(v, p.text(start.Content(), start, finfo), finfo); err != nil {
+			return locate(err, start)
 		}
 
 	case reflect.Struct:
@@ -117,24 +307,27 @@ func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
 			v.Set(reflect.ValueOf(xml.Name{Local: start.Name()}))
 			break
 		}
-		if typ == timeType {
-			if err := copyValue(v, start.Content()); err != nil {
-				return err
+		if isOptional(typ) {
+			return p.unmarshal(v.Addr().Interface().(optional).present(false), start, finfo)
+		}
+		if typ == timeType || isBigType(typ) {
+			if err := p.copyValue(v, p.text(start.Content(), start, finfo), finfo); err != nil {
+				return locate(err, start)
 			}
 			break
 		}
 
 		sv = v
-		tinfo, err = getTypeInfo(typ)
+		plan, err = getDecodePlan(typ, p.NameMapper, p.CaseInsensitive)
 		if err != nil {
 			return err
 		}
 
 		// Validate and assign element name.
-		if tinfo.xmlname != nil {
+		if plan.tinfo.xmlname != nil {
 			// var space string
-			finfo := tinfo.xmlname
-			if finfo.name != "" && finfo.name != start.Name() {
+			finfo := plan.tinfo.xmlname
+			if finfo.name != "" && finfo.name != p.key(start.Name()) {
 				return UnmarshalError("expected element type <" + finfo.name + "> but have <" + start.Name() + ">")
 			}
 
@@ -144,33 +337,65 @@ func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
 			}
 		}
 
+		var batch *sliceBatch
+		if p.Parallel > 1 {
+			batch = new(sliceBatch)
+		}
+
+		var seen *seenFields
+		if len(plan.checks) > 0 {
+			seen = new(seenFields)
+		}
+
 		var saveComment reflect.Value
+		var saveCommentInfo *fieldInfo
 		var doSaveComment = false
-		_ = saveComment
 
-		for i := range tinfo.fields {
-			finfo := &tinfo.fields[i]
+		if len(plan.attrs) > 0 {
+			if err := p.unmarshalAttrs(plan.attrs, sv, start, seen); err != nil {
+				return err
+			}
+		}
+
+		for _, finfo := range plan.other {
 			switch finfo.flags & fMode {
-			case fAttr:
-				strv := sv.FieldByIndex(finfo.idx)
-				for name, a := range start.Attributes() {
-					if name == finfo.name {
-						copyValue(strv, a.Content())
-					}
-				}
 			case fCharData:
 				strv := sv.FieldByIndex(finfo.idx)
-				copyValue(strv, start.Content())
+				if err := p.copyText(strv, p.text(start.Content(), start, finfo), finfo); err != nil {
+					return locate(err, start)
+				}
+
+			case fSelect:
+				if err := p.unmarshalSelect(finfo, sv.FieldByIndex(finfo.idx), start); err != nil {
+					return err
+				}
 
 			case fInnerXml:
 				strv := sv.FieldByIndex(finfo.idx)
 				// TODO: Not sure why i need to call FirstChild() here.
-				copyValue(strv, start.FirstChild().String())
+				p.copyValue(strv, start.FirstChild().String(), finfo)
 
 			case fComment:
 				if !doSaveComment {
 					doSaveComment = true
 					saveComment = sv.FieldByIndex(finfo.idx)
+					saveCommentInfo = finfo
+				}
+			}
+		}
+
+		// The root element also receives the processing instructions
+		// before it in the document.
+		var prolog gokoxml.Node
+		if root && len(plan.pis) > 0 {
+			for prev := start.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+				prolog = prev
+			}
+		}
+		for cur_node := prolog; cur_node != nil && cur_node.NodeType() != gokoxml.XML_ELEMENT_NODE; cur_node = cur_node.NextSibling() {
+			if cur_node.NodeType() == gokoxml.XML_PI_NODE {
+				if err := p.unmarshalPI(plan.pis, sv, cur_node); err != nil {
+					return err
 				}
 			}
 		}
@@ -178,43 +403,92 @@ func (p *Decoder) unmarshal(val reflect.Value, start gokoxml.Node) error {
 		for cur_node := start.FirstChild(); cur_node != nil; cur_node = cur_node.NextSibling() {
 			if sv.IsValid() {
 				if cur_node.NodeType() != gokoxml.XML_ELEMENT_NODE {
-					if doSaveComment && cur_node.NodeType() == gokoxml.XML_COMMENT_NODE {
-						copyValue(saveComment, cur_node.Content())
+					switch cur_node.NodeType() {
+					case gokoxml.XML_COMMENT_NODE:
+						if doSaveComment {
+							if err := p.addComment(saveComment, cur_node.Content(), saveCommentInfo); err != nil {
+								return locate(err, cur_node)
+							}
+						}
+					case gokoxml.XML_PI_NODE:
+						if len(plan.pis) > 0 {
+							if err := p.unmarshalPI(plan.pis, sv, cur_node); err != nil {
+								return err
+							}
+						}
 					}
 					continue
 				}
 
-				err = p.unmarshalPath(tinfo, sv, nil, cur_node)
+				if plan.any != nil && !plan.paths.matches(p.key(cur_node.Name())) {
+					err = p.unmarshalAny(sv.FieldByIndex(plan.any.idx), cur_node, plan.any)
+				} else {
+					_, err = p.unmarshalPath(plan.paths, sv, cur_node, batch, seen)
+				}
 				if err != nil {
 					return err
 				}
 			}
 		}
 
+		if batch != nil {
+			if err := p.unmarshalBatch(sv, batch); err != nil {
+				return err
+			}
+		}
+
+		for _, finfo := range plan.checks {
+			if err := p.checkField(sv, start, finfo, *seen); err != nil {
+				return err
+			}
+		}
+
+		if plan.after || plan.validate {
+			if err := p.runHooks(plan, sv, start); err != nil {
+				return err
+			}
+		}
+
 	} // switch v := val; v.Kind() {
 
 	return nil
 }
 
-func copyValue(dst reflect.Value, src string) (err error) {
-	// Helper functions for integer and unsigned integer conversions
-	var itmp int64
-	getInt64 := func() bool {
-		itmp, err = strconv.ParseInt(src, 10, 64)
-		// TODO: should check sizes
-		return err == nil
+// copyValue stores src in dst, converted as the options of finfo
+// specify. finfo may be nil.
+func (p *decodeState) copyValue(dst reflect.Value, src string, finfo *fieldInfo) (err error) {
+	if dst.IsValid() && isOptional(dst.Type()) {
+		return p.copyValue(dst.Addr().Interface().(optional).present(false), src, finfo)
 	}
-	var utmp uint64
-	getUint64 := func() bool {
-		utmp, err = strconv.ParseUint(src, 10, 64)
-		// TODO: check for overflow?
-		return err == nil
+	if finfo != nil && finfo.facets != nil {
+		// Facets are checked on the value stored.
+		defer func() {
+			if err == nil {
+				err = checkFacets(dst, src, finfo)
+			}
+		}()
 	}
-	var ftmp float64
-	getFloat64 := func() bool {
-		ftmp, err = strconv.ParseFloat(src, 64)
-		// TODO: check for overflow?
-		return err == nil
+
+	if dst.IsValid() {
+		conv, err := p.converter(dst.Type(), finfo)
+		if err != nil {
+			return err
+		}
+		if conv.IsValid() {
+			return convert(conv, dst, src)
+		}
+		if finfo != nil && finfo.conv != "" {
+			return UnmarshalError("xml: converter " + strconv.Quote(finfo.conv) + " does not return " + dst.Type().String())
+		}
+	}
+
+	if dst.IsValid() && dst.Type() == durationType {
+		d, err := parseDuration(src)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(d))
+		return nil
 	}
 
 	// Save accumulated data.
@@ -224,17 +498,37 @@ func copyValue(dst reflect.Value, src string) (err error) {
 	default:
 		return errors.New("cannot happen: unknown type " + t.Type().String())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if !getInt64() {
+		// Numbers are trimmed and parsed with the size of dst, so
+		// values that do not fit are reported instead of truncated.
+		src = strings.TrimSpace(src)
+		if src == "" {
+			t.SetInt(0)
+			break
+		}
+		itmp, err := strconv.ParseInt(src, 10, t.Type().Bits())
+		if err != nil {
 			return err
 		}
 		t.SetInt(itmp)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		if !getUint64() {
+		src = strings.TrimSpace(src)
+		if src == "" {
+			t.SetUint(0)
+			break
+		}
+		utmp, err := strconv.ParseUint(src, 10, t.Type().Bits())
+		if err != nil {
 			return err
 		}
 		t.SetUint(utmp)
 	case reflect.Float32, reflect.Float64:
-		if !getFloat64() {
+		src = strings.TrimSpace(src)
+		if src == "" {
+			t.SetFloat(0)
+			break
+		}
+		ftmp, err := strconv.ParseFloat(src, t.Type().Bits())
+		if err != nil {
 			return err
 		}
 		t.SetFloat(ftmp)
@@ -247,74 +541,201 @@ func copyValue(dst reflect.Value, src string) (err error) {
 	case reflect.String:
 		t.SetString(src)
 	case reflect.Slice:
-		t.SetBytes([]byte(src))
+		b, err := decodeBinary(src, finfo)
+		if err != nil {
+			return err
+		}
+		t.SetBytes(b)
 	case reflect.Struct:
 		if t.Type() == timeType {
-			tv, err := time.Parse(time.RFC3339, src)
+			tv, err := p.parseTime(src, finfo)
 			if err != nil {
 				return err
 			}
 			t.Set(reflect.ValueOf(tv))
+		} else if isBigType(t.Type()) {
+			return copyBig(t, src)
 		}
 	}
 	return nil
 }
 
 // unmarshalPath walks down an XML structure looking for wanted
-// paths, and calls unmarshal on them.
-// The consumed result tells whether XML elements have been consumed
-// from the Decoder until start's matching end element, or if it's
-// still untouched because start is uninteresting for sv's fields.
-func (p *Decoder) unmarshalPath(tinfo *typeInfo, sv reflect.Value, parents []string, start gokoxml.Node) (err error) {
-	recurse := false
-	name := start.Name() // For speed
-
-Loop:
-	for i := range tinfo.fields {
-		finfo := &tinfo.fields[i]
-		if finfo.flags&fElement == 0 || len(finfo.parents) < len(parents) {
+// paths, and calls unmarshal on them. paths is the trie node that
+// start's parent element has been matched against. Elements of
+// slice fields are added to batch instead, unless it is nil. The
+// fields bound are recorded in seen, unless it is nil. It tells
+// whether start itself was bound to a field.
+func (p *decodeState) unmarshalPath(paths *pathNode, sv reflect.Value, start gokoxml.Node, batch *sliceBatch, seen *seenFields) (bool, error) {
+	bound := false
+	for _, node := range [2]*pathNode{paths.children[p.key(start.Name())], paths.children["*"]} {
+		if node == nil {
+			// We have no business with this element.
 			continue
 		}
-		for j := range parents {
-			if parents[j] != finfo.parents[j] {
-				continue Loop
+		b, err := p.unmarshalNode(node, sv, start, batch, seen)
+		bound = bound || b
+		if err != nil {
+			return bound, err
+		}
+	}
+
+	if paths.desc != nil {
+		if err := p.unmarshalDesc(paths.desc, sv, start, batch, seen); err != nil {
+			return bound, err
+		}
+	}
+	return bound, nil
+}
+
+// unmarshalNode unmarshals start, which matched the trie node node, and
+// tells whether it was bound to a field.
+func (p *decodeState) unmarshalNode(node *pathNode, sv reflect.Value, start gokoxml.Node, batch *sliceBatch, seen *seenFields) (bool, error) {
+	bound := false
+	if node.attrs != nil {
+		if err := p.unmarshalAttrs(node.attrs, sv, start, seen); err != nil {
+			return bound, err
+		}
+	}
+
+	if node.finfo != nil {
+		// It's a perfect match, unmarshal the field.
+		bound = true
+		seen.add(node.finfo)
+		if node.slice && batch != nil {
+			batch.add(node.finfo, start)
+		} else if err := p.unmarshal(sv.FieldByIndex(node.finfo.idx), start, node.finfo); err != nil {
+			return bound, err
+		}
+	}
+
+	if node.children != nil || node.desc != nil {
+		// One or more fields have the path to this element as a
+		// parent prefix. Recurse and attempt to match these. Below
+		// the element of a field, these are attribute paths only.
+		for cur_node := start.FirstChild(); cur_node != nil; cur_node = cur_node.NextSibling() {
+			if cur_node.NodeType() != gokoxml.XML_ELEMENT_NODE {
+				continue
+			}
+			if _, err := p.unmarshalPath(node, sv, cur_node, batch, seen); err != nil {
+				return bound, err
 			}
 		}
-		if len(finfo.parents) == len(parents) && finfo.name == name {
-			// It's a perfect match, unmarshal the field.
-			return p.unmarshal(sv.FieldByIndex(finfo.idx), start)
+	}
+
+	// Paths with a predicate on this step continue from their own
+	// nodes, if start satisfies it.
+	for _, pn := range node.preds {
+		if !p.satisfies(start, pn.pred) {
+			continue
+		}
+		b, err := p.unmarshalNode(pn.node, sv, start, batch, seen)
+		bound = bound || b
+		if err != nil {
+			return bound, err
 		}
-		if len(finfo.parents) > len(parents) && finfo.parents[len(parents)] == name {
-			// It's a prefix for the field. Break and recurse
-			// since it's not ok for one field path to be itself
-			// the prefix for another field path.
-			recurse = true
+	}
+	return bound, nil
+}
 
-			// We can reuse the same slice as long as we
-			// don't try to append to it.
-			parents = finfo.parents[:len(parents)+1]
-			break
+// unmarshalAttrs copies the attributes of start to their fields in
+// attrs. Slices receive the values of all elements matching their
+// path. The fields bound are recorded in seen, unless it is nil.
+func (p *decodeState) unmarshalAttrs(attrs map[string]*fieldInfo, sv reflect.Value, start gokoxml.Node, seen *seenFields) error {
+	for name, a := range start.Attributes() {
+		finfo, ok := attrs[p.key(name)]
+		if !ok {
+			continue
+		}
+		seen.add(finfo)
+		fv := sv.FieldByIndex(finfo.idx)
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			n := fv.Len()
+			if err := p.Limits.checkSlice(n + 1); err != nil {
+				return err
+			}
+			fv.Set(reflect.Append(fv, reflect.Zero(fv.Type().Elem())))
+			if err := p.copyText(fv.Index(n), p.text(a.Content(), start, finfo), finfo); err != nil {
+				fv.SetLen(n)
+				return locate(err, a)
+			}
+			continue
+		}
+		if err := p.copyText(fv, p.text(a.Content(), start, finfo), finfo); err != nil {
+			return locate(err, a)
 		}
 	}
+	return nil
+}
 
-	if !recurse {
-		// We have no business with this element.
-		return nil
+// copyText stores src in dst like copyValue, allocating the values
+// pointers point to. A nil pointer stays nil if src cannot be stored.
+func (p *decodeState) copyText(dst reflect.Value, src string, finfo *fieldInfo) error {
+	if dst.Kind() != reflect.Ptr {
+		return p.copyValue(dst, src, finfo)
 	}
+	if !dst.IsNil() {
+		return p.copyText(dst.Elem(), src, finfo)
+	}
+	v := reflect.New(dst.Type().Elem())
+	if err := p.copyText(v.Elem(), src, finfo); err != nil {
+		return err
+	}
+	dst.Set(v)
+	return nil
+}
 
-	// The element is not a perfect match for any field, but one
-	// or more fields have the path to this element as a parent
-	// prefix. Recurse and attempt to match these.
+// unmarshalDesc matches start and the elements below it against the
+// paths continuing at any depth from desc. The elements below an
+// element bound to a field are not looked at.
+func (p *decodeState) unmarshalDesc(desc *pathNode, sv reflect.Value, start gokoxml.Node, batch *sliceBatch, seen *seenFields) error {
+	bound, err := p.unmarshalPath(desc, sv, start, batch, seen)
+	if err != nil || bound {
+		return err
+	}
 	for cur_node := start.FirstChild(); cur_node != nil; cur_node = cur_node.NextSibling() {
 		if cur_node.NodeType() != gokoxml.XML_ELEMENT_NODE {
 			continue
 		}
-
-		if err := p.unmarshalPath(tinfo, sv, parents, cur_node); err != nil {
+		if err := p.unmarshalDesc(desc, sv, cur_node, batch, seen); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// unmarshalAny unmarshals start, which no path matched, into the ,any
+// field fv. Interface fields skip the elements there is no type for.
+func (p *decodeState) unmarshalAny(fv reflect.Value, start gokoxml.Node, finfo *fieldInfo) error {
+	typ := fv.Type()
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Interface && !p.hasType(start, typ) {
+		return nil
+	}
+	return p.unmarshal(fv, start, finfo)
+}
+
+// unmarshalSelect evaluates the selector of finfo below start and
+// unmarshals the matching nodes into fv. Slices receive every match,
+// other kinds only the first one.
+func (p *decodeState) unmarshalSelect(finfo *fieldInfo, fv reflect.Value, start gokoxml.Node) error {
+	p.searchLock.Lock()
+	nodes, err := start.Search(finfo.xpath)
+	p.searchLock.Unlock()
+	if err != nil {
+		return err
+	}
 
-	// No more XML Nodes.
+	many := fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8
+	for _, node := range nodes {
+		if err := p.unmarshal(fv, node, finfo); err != nil {
+			return err
+		}
+		if !many {
+			break
+		}
+	}
 	return nil
 }
@@ -2,9 +2,13 @@ package xml
 
 import (
 	coreXML "encoding/xml"
+	"fmt"
+	gokoxml "github.com/moovweb/gokogiri/xml"
 	"io/ioutil"
 	. "launchpad.net/gocheck"
 	"reflect"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -99,6 +103,25 @@ func (s *lXMLSuite) BenchmarkLXML(c *C) {
 	}
 }
 
+// BenchmarkLXMLBind measures binding only, the document is parsed once.
+func (s *lXMLSuite) BenchmarkLXMLBind(c *C) {
+	doc, err := gokoxml.Parse(s.ecs_xml, gokoxml.DefaultEncodingBytes, nil, gokoxml.DefaultParseOption, gokoxml.DefaultEncodingBytes)
+	if err != nil {
+		c.Fatalf("ERROR: %v\n", err)
+	}
+	defer doc.Free()
+
+	p := &decodeState{Decoder: new(Decoder), doc: doc}
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		v := ECSResponse{}
+		err := p.unmarshal(reflect.ValueOf(&v).Elem(), nil, nil)
+		if err != nil {
+			c.Fatalf("ERROR: %v\n", err)
+		}
+	}
+}
+
 func (s *lXMLSuite) BenchmarkFlatLXML(c *C) {
 	for i := 0; i < c.N; i++ {
 		v := ECSResponse{}
@@ -109,6 +132,34 @@ func (s *lXMLSuite) BenchmarkFlatLXML(c *C) {
 	}
 }
 
+func (s *lXMLSuite) BenchmarkLXMLParallel(c *C) {
+	d := new(Decoder)
+	n := runtime.GOMAXPROCS(0)
+	// Fatalf must not be called from other goroutines, the first error
+	// of each goroutine is reported once all are done.
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < c.N; i += n {
+				v := ECSResponse{}
+				if err := d.Decode(s.ecs_xml, &v); err != nil {
+					errs[g] = err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			c.Fatalf("ERROR: %v\n", err)
+		}
+	}
+}
+
 func (s *lXMLSuite) TestCoreXMLvLXMLEqual(c *C) {
 	res := ECSResponse{}
 	err := Unmarshal(s.ecs_xml, &res)
@@ -125,6 +176,41 @@ func (s *lXMLSuite) TestCoreXMLvLXMLEqual(c *C) {
 	c.Check(res, DeepEquals, res2)
 }
 
+func (s *lXMLSuite) TestDecoderConcurrent(c *C) {
+	want := ECSResponse{}
+	if err := coreXML.Unmarshal(s.ecs_xml, &want); err != nil {
+		c.Fatalf("ERROR: %v\n", err)
+	}
+
+	d := new(Decoder)
+	errs := make(chan error, 8)
+	for g := 0; g < cap(errs); g++ {
+		go func() {
+			for i := 0; i < 20; i++ {
+				v := ECSResponse{}
+				if err := d.Decode(s.ecs_xml, &v); err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(v, want) {
+					errs <- fmt.Errorf("decoded %+v", v)
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for g := 0; g < cap(errs); g++ {
+		c.Check(<-errs, IsNil)
+	}
+}
+
+func (s *lXMLSuite) TestDecodeInvalid(c *C) {
+	var v ECSResponse
+	c.Check(Unmarshal(nil, &v), NotNil)
+	c.Check(Unmarshal([]byte("<?xml version=\"1.0\"?>"), &v), NotNil)
+}
+
 const pathTestString = `
 <Result>
     <Before>1</Before>
@@ -0,0 +1,121 @@
+// Copyright 2012 Rene Jochum.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+/*
+#cgo pkg-config: libxslt
+#include <stdlib.h>
+#include <libxslt/xslt.h>
+#include <libxslt/xsltInternals.h>
+#include <libxslt/transform.h>
+
+static const char **makeParams(int n) {
+	return calloc(n + 1, sizeof(char *));
+}
+
+static void setParam(const char **params, int i, char *s) {
+	params[i] = s;
+}
+
+static void freeParams(const char **params, int n) {
+	int i;
+	for (i = 0; i < n; i++) {
+		free((char *)params[i]);
+	}
+	free(params);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	gokoxml "github.com/moovweb/gokogiri/xml"
+	"strings"
+	"unsafe"
+)
+
+// A Stylesheet is a compiled XSLT stylesheet. It is safe to share a
+// Stylesheet between Decoders and goroutines.
+type Stylesheet struct {
+	ptr C.xsltStylesheetPtr
+}
+
+// ParseStylesheet compiles the XSLT stylesheet in data.
+func ParseStylesheet(data []byte) (*Stylesheet, error) {
+	doc, err := gokoxml.Parse(data, gokoxml.DefaultEncodingBytes, nil, gokoxml.DefaultParseOption, gokoxml.DefaultEncodingBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// On success the stylesheet owns doc and frees it along with itself.
+	ptr := C.xsltParseStylesheetDoc(C.xmlDocPtr(doc.DocPtr()))
+	if ptr == nil {
+		doc.Free()
+		return nil, errors.New("xslt: invalid stylesheet")
+	}
+	return &Stylesheet{ptr: ptr}, nil
+}
+
+// Free releases the compiled stylesheet. It must not be used afterwards.
+func (s *Stylesheet) Free() {
+	if s.ptr != nil {
+		C.xsltFreeStylesheet(s.ptr)
+		s.ptr = nil
+	}
+}
+
+// transform is a stylesheet attached to a Decoder together with its
+// parameters, already quoted as XPath string literals.
+type transform struct {
+	style  *Stylesheet
+	params []string
+}
+
+// Transform attaches style to d. Before binding, the parsed document is
+// run through every attached stylesheet in the order they were added,
+// each working on the result tree of the previous one. params are
+// passed to the stylesheet as string parameters.
+func (d *Decoder) Transform(style *Stylesheet, params map[string]string) {
+	t := transform{style: style}
+	for name, value := range params {
+		t.params = append(t.params, name, xpathString(value))
+	}
+	d.transforms = append(d.transforms, t)
+}
+
+// apply runs the stylesheet on doc and returns the result tree.
+// doc is left untouched.
+func (t *transform) apply(doc *gokoxml.XmlDocument) (*gokoxml.XmlDocument, error) {
+	n := len(t.params)
+	params := C.makeParams(C.int(n))
+	for i, p := range t.params {
+		C.setParam(params, C.int(i), C.CString(p))
+	}
+	defer C.freeParams(params, C.int(n))
+
+	res := C.xsltApplyStylesheet(t.style.ptr, C.xmlDocPtr(doc.DocPtr()), params)
+	if res == nil {
+		return nil, errors.New("xslt: transformation failed")
+	}
+
+	out := gokoxml.NewDocument(unsafe.Pointer(res), 0, gokoxml.DefaultEncodingBytes, gokoxml.DefaultEncodingBytes)
+	if out.Root() == nil {
+		out.Free()
+		return nil, errors.New("xslt: transformation produced no root element")
+	}
+	return out, nil
+}
+
+// xpathString quotes s as an XPath 1.0 string literal.
+func xpathString(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	// XPath 1.0 has no escapes, so splice the single quotes in.
+	return "concat('" + strings.Replace(s, "'", `', "'", '`, -1) + "')"
+}
@@ -0,0 +1,100 @@
+package xml
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+const xsltTestStylesheet = `<?xml version="1.0"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:param name="source"/>
+  <xsl:template match="/Products">
+    <Items Source="{$source}">
+      <xsl:for-each select="Product">
+        <Item>
+          <ASIN><xsl:value-of select="@id"/></ASIN>
+          <Title><xsl:value-of select="Name"/></Title>
+        </Item>
+      </xsl:for-each>
+    </Items>
+  </xsl:template>
+</xsl:stylesheet>`
+
+const xsltTestUpper = `<?xml version="1.0"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:template match="@*|node()">
+    <xsl:copy><xsl:apply-templates select="@*|node()"/></xsl:copy>
+  </xsl:template>
+  <xsl:template match="Title/text()">
+    <xsl:value-of select="translate(., 'abcdefghijklmnopqrstuvwxyz', 'ABCDEFGHIJKLMNOPQRSTUVWXYZ')"/>
+  </xsl:template>
+</xsl:stylesheet>`
+
+const xsltTestData = `<?xml version="1.0"?>
+<Products>
+  <Product id="B001"><Name>first</Name></Product>
+  <Product id="B002"><Name>second</Name></Product>
+</Products>`
+
+type xsltTestItem struct {
+	ASIN  string
+	Title string
+}
+
+type xsltTestResult struct {
+	Source string         `xml:",attr"`
+	Items  []xsltTestItem `xml:"Item"`
+}
+
+func (s *lXMLSuite) TestTransform(c *C) {
+	style, err := ParseStylesheet([]byte(xsltTestStylesheet))
+	c.Assert(err, IsNil)
+	defer style.Free()
+	upper, err := ParseStylesheet([]byte(xsltTestUpper))
+	c.Assert(err, IsNil)
+	defer upper.Free()
+
+	d := new(Decoder)
+	d.Transform(style, map[string]string{"source": "it's \"quoted\""})
+	d.Transform(upper, nil)
+
+	var v xsltTestResult
+	c.Assert(d.Decode([]byte(xsltTestData), &v), IsNil)
+	c.Check(v, DeepEquals, xsltTestResult{
+		Source: "it's \"quoted\"",
+		Items:  []xsltTestItem{{"B001", "FIRST"}, {"B002", "SECOND"}},
+	})
+}
+
+func (s *lXMLSuite) TestParseStylesheetInvalid(c *C) {
+	_, err := ParseStylesheet([]byte(`<NotAStylesheet/>`))
+	c.Check(err, NotNil)
+}
+
+func (s *lXMLSuite) TestXPathString(c *C) {
+	c.Check(xpathString(`a`), Equals, `'a'`)
+	c.Check(xpathString(`a'b`), Equals, `"a'b"`)
+	c.Check(xpathString(`a'b"c`), Equals, `concat('a', "'", 'b"c')`)
+}
+
+func (s *lXMLSuite) TestTransformLimits(c *C) {
+	empty, err := ParseStylesheet([]byte(`<?xml version="1.0"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:template match="/"><Items/></xsl:template>
+</xsl:stylesheet>`))
+	c.Assert(err, IsNil)
+	defer empty.Free()
+	style, err := ParseStylesheet([]byte(xsltTestStylesheet))
+	c.Assert(err, IsNil)
+	defer style.Free()
+
+	// The input is limited, even if the transform leaves less.
+	d := &Decoder{Limits: Limits{Elements: 4}}
+	d.Transform(empty, nil)
+	var v xsltTestResult
+	c.Check(d.Decode([]byte(xsltTestData), &v), DeepEquals, &LimitError{"Elements", 4})
+
+	// So is the result of a transform.
+	d = &Decoder{Limits: Limits{Elements: 6}}
+	d.Transform(style, nil)
+	c.Check(d.Decode([]byte(xsltTestData), &v), DeepEquals, &LimitError{"Elements", 6})
+}